@@ -3,25 +3,38 @@ package bootstrap
 import (
 	"fmt"
 	repo "payment/internal/repositories/pg-gorm"
+	"payment/internal/services"
 	"payment/pkg/core/configloader"
 	"payment/pkg/core/db"
+	"payment/pkg/core/health"
 )
 
 type App struct {
 	Config *configloader.Config
 	PGRepo repo.PGInterface
-}
 
-// initializeApp initializes all application dependencies
-func InitializeApp() (*App, error) {
-	config := configloader.GetConfig()
+	PaymentService *services.PaymentService
+	RefundService  *services.RefundService
+	WebhookService *services.WebhookService
+
+	Health *health.Checker
+}
 
+// InitializeApp initializes all application dependencies with the loaded config
+func InitializeApp(config *configloader.Config) (*App, error) {
 	// Initialize database
 	dbBackend, err := db.InitDatabase(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
+	if err := dbBackend.Use(db.QueryMetrics{}); err != nil {
+		return nil, fmt.Errorf("failed to register query metrics: %w", err)
+	}
+	if err := dbBackend.Use(db.QueryTracing{}); err != nil {
+		return nil, fmt.Errorf("failed to register query tracing: %w", err)
+	}
+
 	pgRepo := repo.NewPGRepo(dbBackend)
 
 	return &App{
@@ -0,0 +1,32 @@
+package bootstrap
+
+import (
+	"payment/internal/gateway"
+	"payment/pkg/core/configloader"
+)
+
+// NewGateway builds the payment gateway used by PaymentService, instrumented with metrics
+// and tracing.
+// Only the in-process simulator is wired for now.
+func NewGateway(cfg *configloader.Config) gateway.Gateway {
+	var rules []gateway.SimulatorRule
+	for _, token := range cfg.GatewaySimTimeoutTokens {
+		rules = append(rules, gateway.SimulatorRule{CardToken: token, Outcome: gateway.OutcomeTimeout})
+	}
+	for _, token := range cfg.GatewaySimDeclineTokens {
+		rules = append(rules, gateway.SimulatorRule{CardToken: token, Outcome: gateway.OutcomeDecline, Reason: "card declined"})
+	}
+	if cfg.GatewaySimDeclineAmount > 0 {
+		rules = append(rules, gateway.SimulatorRule{
+			MinAmount: cfg.GatewaySimDeclineAmount,
+			Outcome:   gateway.OutcomeDecline,
+			Reason:    "amount over limit",
+		})
+	}
+
+	return gateway.NewInstrumented(gateway.NewSimulator(gateway.SimulatorConfig{
+		Latency: cfg.GatewaySimLatency,
+		Rules:   rules,
+		Default: gateway.OutcomeApprove,
+	}))
+}
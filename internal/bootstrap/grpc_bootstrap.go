@@ -1,45 +1,71 @@
 package bootstrap
 
 import (
-	"context"
 	"fmt"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"payment/internal/grpc/handlers"
+	"payment/internal/grpc/interceptors"
 	"payment/internal/grpc/server"
-	"payment/internal/repositories"
-	"payment/internal/services"
-	"payment/pkg/core/kafka"
-	"strconv"
+	pb "payment/pkg/proto/paymentpb"
 )
 
-func StartGRPC(app *App, kafkaApp kafka.App) (*server.GRPCServer, error) {
+// NewGRPC builds the gRPC server and its REST gateway, they listen once started
+func NewGRPC(app *App) *server.GRPCServer {
+	grpcAddr := fmt.Sprintf(":%d", app.Config.GRPCPort)
+	httpAddr := fmt.Sprintf(":%d", app.Config.HTTPPort)
 
-	grpcPort, err := strconv.Atoi(app.Config.GRPCPort)
-	if err != nil || grpcPort == 0 {
-		grpcPort = 50052
-	}
-	httpPort, err := strconv.Atoi(app.Config.HTTPPort)
-	if err != nil || httpPort == 0 {
-		httpPort = 8081
-	}
-
-	grpcAddr := fmt.Sprintf(":%d", grpcPort)
-	httpAddr := fmt.Sprintf(":%d", httpPort)
+	handler := handlers.NewPaymentHandler(app.PaymentService, app.PaymentService, app.RefundService)
 
-	newPgRepo := app.PGRepo
-
-	paymentRepo := repositories.NewPaymentRepository(newPgRepo)
-	paymentService := services.NewPaymentService(paymentRepo, *kafkaApp.Producer)
-	handler := handlers.NewPaymentHandler(paymentService)
+	grpcServer := server.NewGRPCServer(handler, grpcAddr, httpAddr, grpcServerOptions(app)...)
+	grpcServer.RegisterHealth(app.Health.GRPCServer())
+	return grpcServer
+}
 
-	grpcServer := server.NewGRPCServer(handler, grpcAddr, httpAddr)
+// grpcServerOptions chains the interceptors: the request id comes first so every log line
+// carries it, and recovery sits inside logging and metrics so a recovered panic is logged
+// and observed with its code. Server spans come from the otelgrpc stats handler, which
+// continues the trace found in the incoming metadata.
+func grpcServerOptions(app *App) []grpc.ServerOption {
+	unary := []grpc.UnaryServerInterceptor{
+		interceptors.RequestIDUnary(),
+		interceptors.LoggingUnary(),
+		interceptors.MetricsUnary(),
+		interceptors.RecoveryUnary(),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		interceptors.RequestIDStream(),
+		interceptors.LoggingStream(),
+		interceptors.MetricsStream(),
+		interceptors.RecoveryStream(),
+	}
+	if app.Config.GRPCAuthEnabled {
+		// Health probes come from the orchestrator, which holds no token
+		auth := interceptors.NewAuth(app.Config.JWTAccessSecure,
+			healthpb.Health_Check_FullMethodName,
+			healthpb.Health_List_FullMethodName,
+			healthpb.Health_Watch_FullMethodName,
+		).Require(paymentWriterRole, paymentMethods("Pay", "Capture", "Void", "Refund")...)
+		unary = append(unary, auth.Unary())
+		stream = append(stream, auth.Stream())
+	}
 
-	ctx := context.Background()
+	return []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+}
 
-	go func() {
-		if err := grpcServer.Run(ctx); err != nil {
-			panic(err)
-		}
-	}()
+// paymentWriterRole is the token role allowed to move money, as on the HTTP API
+const paymentWriterRole = "admin"
 
-	return grpcServer, nil
+// paymentMethods returns the full gRPC method names of PaymentService RPCs
+func paymentMethods(names ...string) []string {
+	methods := make([]string, 0, len(names))
+	for _, name := range names {
+		methods = append(methods, "/"+pb.PaymentService_ServiceDesc.ServiceName+"/"+name)
+	}
+	return methods
 }
@@ -0,0 +1,54 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"payment/internal/migrations"
+	"payment/pkg/core/health"
+	"payment/pkg/core/kafka"
+	pb "payment/pkg/proto/paymentpb"
+)
+
+// InitHealth builds the dependency checks behind /readyz and the gRPC health service:
+// the database answers a ping, a Kafka broker accepts a connection and every
+// migration has been applied
+func InitHealth(app *App) {
+	checker := health.NewChecker(app.Config.HealthCheckInterval, app.Config.HealthCheckTimeout,
+		pb.PaymentService_ServiceDesc.ServiceName)
+
+	checker.Add("postgres", app.PGRepo.Ping)
+	checker.Add("kafka", func(ctx context.Context) error {
+		return kafka.Ping(ctx, app.Config.KafkaBrokers)
+	})
+	runner := migrations.NewRunner(app.PGRepo.GetRepo(), io.Discard)
+	checker.Add("migrations", func(ctx context.Context) error {
+		pending, err := runner.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migrations, latest is %s", len(pending), pending[len(pending)-1].ID)
+		}
+		return nil
+	})
+
+	app.Health = checker
+}
+
+// NewHealthChecker runs the dependency checks. It is started last and stopped first, so
+// the service only reports SERVING once everything runs and reports NOT_SERVING as soon
+// as shutdown starts, before the servers drain.
+func NewHealthChecker(app *App) Component {
+	return &healthComponent{worker: newWorker("health checker", app.Health.Run), checker: app.Health}
+}
+
+type healthComponent struct {
+	*worker
+	checker *health.Checker
+}
+
+func (h *healthComponent) Stop(ctx context.Context) error {
+	h.checker.Shutdown()
+	return h.worker.Stop(ctx)
+}
@@ -1,25 +1,53 @@
 package bootstrap
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"payment/pkg/core/configloader"
 )
 
-func StartServer(router http.Handler, cfg *configloader.Config) (*http.Server, <-chan error) {
+// NewHTTPServer serves router on SERVER_PORT
+func NewHTTPServer(router http.Handler, cfg *configloader.Config) Component {
+	return &httpComponent{
+		server: &http.Server{Addr: fmt.Sprintf(":%d", cfg.ServerPort), Handler: router},
+		failed: make(chan error, 1),
+	}
+}
+
+type httpComponent struct {
+	server *http.Server
+	failed chan error
+}
+
+func (h *httpComponent) Name() string {
+	return "http server"
+}
 
-	port := fmt.Sprintf(":%s", cfg.ServerPort)
-	s := &http.Server{Addr: port, Handler: router}
-	errCh := make(chan error, 1)
+// Start listens right away so a taken port fails the startup
+func (h *httpComponent) Start(ctx context.Context) error {
+	lis, err := net.Listen("tcp", h.server.Addr)
+	if err != nil {
+		return err
+	}
 
 	go func() {
-		log.Printf("HTTP server starting on %s", port)
-		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errCh <- err
+		log.Printf("HTTP server starting on %s", h.server.Addr)
+		if err := h.server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			h.failed <- err
 		}
-		close(errCh)
 	}()
+	return nil
+}
+
+func (h *httpComponent) Failed() <-chan error {
+	return h.failed
+}
 
-	return s, errCh
+// Stop waits for in-flight requests until ctx is done
+func (h *httpComponent) Stop(ctx context.Context) error {
+	return h.server.Shutdown(ctx)
 }
@@ -2,37 +2,99 @@ package bootstrap
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"payment/pkg/core/configloader"
+	"payment/internal/kafka/handlers"
 	"payment/pkg/core/kafka"
 	"payment/pkg/core/kafka/payment"
 )
 
-func InitKafka(parent context.Context) (*kafka.App, func()) {
-	cfg := configloader.GetConfig()
-
-	producer := payment.NewPaymentProducer(cfg.KafkaBrokers, cfg.KafkaTopicPaymentAuthorized)
-	log.Println("Kafka producer created:", cfg.KafkaBrokers, "topic:", cfg.KafkaTopicPaymentAuthorized)
-	//consumer := kafka.NewConsumer(cfg.KafkaBrokers, cfg.KafkaTopicOrder, "order_group")
-	//
-	//ctx, cancel := context.WithCancel(parent)
-	//
-	//go consumer.Listen(ctx, func(data []byte) {
-	//	log.Printf("Received: %s", string(data))
-	//})
-
-	stop := func() {
-		//cancel()
-		if err := producer.Close(); err != nil {
-			log.Printf("producer close error: %v", err)
-		}
-		//if err := consumer.Reader.Close(); err != nil {
-		//	log.Printf("consumer close error: %v", err)
-		//}
-	}
-
-	return &kafka.App{
+// KafkaComponent owns the payment producer and the order-created consumer. Its Stop
+// lets the consumer finish the message in flight, then closes the reader and flushes
+// the producer, so it must be stopped after every component publishing through it.
+type KafkaComponent struct {
+	Producer *payment.Producer
+	consumer *kafka.Consumer
+	handler  func(context.Context, []byte) error
+	worker   *worker
+}
+
+// NewKafka builds the producer and the consumer, they connect once started
+func NewKafka(app *App) (*KafkaComponent, error) {
+	cfg := app.Config
+
+	serializer, err := payment.NewSerializer(cfg.KafkaEventEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KAFKA_EVENT_ENCODING: %w", err)
+	}
+	writerCfg := kafka.WriterConfig{
+		Brokers:      cfg.KafkaBrokers,
+		RequiredAcks: cfg.KafkaProducerRequiredAcks,
+		MaxAttempts:  cfg.KafkaProducerMaxAttempts,
+		BatchSize:    cfg.KafkaProducerBatchSize,
+		BatchTimeout: cfg.KafkaProducerBatchTimeout,
+		Compression:  cfg.KafkaProducerCompression,
+		Async:        cfg.KafkaProducerAsync,
+	}
+	writer, err := kafka.NewWriter(writerCfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kafka producer config: %w", err)
+	}
+	producer := payment.NewPaymentProducer(writer, cfg.KafkaTopicPaymentAuthorized, cfg.KafkaEventTopics, serializer)
+
+	// Dead-letter writes must be confirmed before the offset is committed, never async
+	dlqCfg := writerCfg
+	dlqCfg.Topic = cfg.KafkaTopicOrderDeadLetter
+	dlqCfg.Async = false
+	dlqWriter, err := kafka.NewWriter(dlqCfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kafka dead-letter writer config: %w", err)
+	}
+	// Order events are plain JSON from the order service, the payment event decoder does
+	// not apply to them
+	consumer := kafka.NewConsumer(cfg.KafkaBrokers, cfg.KafkaTopicOrder, cfg.KafkaOrderGroupID, dlqWriter)
+	consumer.Retry = kafka.RetryPolicy{
+		MaxRetries: cfg.KafkaConsumerMaxRetries,
+		Backoff:    cfg.KafkaConsumerRetryBackoff,
+		MaxBackoff: cfg.KafkaConsumerMaxBackoff,
+	}
+	log.Println("Kafka consumer created:", cfg.KafkaBrokers, "topic:", cfg.KafkaTopicOrder, "group:", cfg.KafkaOrderGroupID,
+		"dead-letter topic:", cfg.KafkaTopicOrderDeadLetter)
+
+	k := &KafkaComponent{
 		Producer: producer,
-		//Consumer: consumer,
-	}, stop
+		consumer: consumer,
+		handler:  handlers.NewOrderHandler(app.PaymentService).HandleOrderCreated,
+	}
+	k.worker = newWorker("kafka consumer", func(ctx context.Context) {
+		k.consumer.Listen(ctx, k.handler)
+	})
+	return k, nil
+}
+
+func (k *KafkaComponent) Name() string {
+	return "kafka"
+}
+
+func (k *KafkaComponent) Start(ctx context.Context) error {
+	return k.worker.Start(ctx)
+}
+
+func (k *KafkaComponent) Stop(ctx context.Context) error {
+	var errs []error
+	if err := k.worker.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("drain consumer: %w", err))
+	}
+	if err := k.consumer.Reader.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close consumer: %w", err))
+	}
+	if err := k.consumer.DeadLetter.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close dead-letter writer: %w", err))
+	}
+	// Close flushes the batches still buffered by an async producer
+	if err := k.Producer.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close producer: %w", err))
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,157 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment/pkg/core/logger"
+	"time"
+)
+
+// Component is a long running part of the service managed by Lifecycle
+type Component interface {
+	Name() string
+	// Start brings the component up and returns once it runs, background work must
+	// stop once ctx is cancelled
+	Start(ctx context.Context) error
+	// Stop drains the component, giving up when ctx is done
+	Stop(ctx context.Context) error
+}
+
+// Failer is implemented by components that can fail after Start, eg. a server whose
+// listener breaks. A failure shuts the whole service down.
+type Failer interface {
+	Failed() <-chan error
+}
+
+// Lifecycle starts components in the order they are added and stops them in reverse,
+// so a component is only stopped once everything depending on it has drained
+type Lifecycle struct {
+	components      []Component
+	shutdownTimeout time.Duration
+}
+
+func NewLifecycle(shutdownTimeout time.Duration) *Lifecycle {
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = 30 * time.Second
+	}
+	return &Lifecycle{shutdownTimeout: shutdownTimeout}
+}
+
+// Add appends components, each must only depend on the ones added before it
+func (l *Lifecycle) Add(components ...Component) {
+	l.components = append(l.components, components...)
+}
+
+// Run starts every component and blocks until ctx is cancelled or a component fails,
+// then stops the started components within the shutdown timeout. The returned error
+// joins the start or run failure with every stop error.
+func (l *Lifecycle) Run(ctx context.Context) error {
+	log := logger.WithTag("Lifecycle|Run")
+
+	// Components are stopped one by one, so the root context only follows ctx for its
+	// values and is cancelled once everything has stopped
+	rootCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	failed := make(chan error, len(l.components))
+	var (
+		started []Component
+		cause   error
+	)
+	for _, c := range l.components {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := c.Start(rootCtx); err != nil {
+			cause = fmt.Errorf("start %s: %w", c.Name(), err)
+			break
+		}
+		log.Infof("%s started", c.Name())
+		started = append(started, c)
+
+		if f, ok := c.(Failer); ok {
+			go forwardFailure(rootCtx, c.Name(), f, failed)
+		}
+	}
+
+	if cause == nil {
+		select {
+		case <-ctx.Done():
+			log.Infof("shutting down: %v", context.Cause(ctx))
+		case cause = <-failed:
+			logger.LogError(log, cause, "component failed, shutting down")
+		}
+	} else {
+		logger.LogError(log, cause, "startup failed, stopping started components")
+	}
+
+	return errors.Join(cause, l.stop(started))
+}
+
+// stop stops components in reverse start order, all within one shutdown deadline
+func (l *Lifecycle) stop(started []Component) error {
+	log := logger.WithTag("Lifecycle|stop")
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		if err := c.Stop(ctx); err != nil {
+			logger.LogError(log, err, "failed to stop "+c.Name())
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.Name(), err))
+			continue
+		}
+		log.Infof("%s stopped", c.Name())
+	}
+	return errors.Join(errs...)
+}
+
+func forwardFailure(ctx context.Context, name string, f Failer, failed chan<- error) {
+	select {
+	case err, ok := <-f.Failed():
+		if ok && err != nil {
+			failed <- fmt.Errorf("%s: %w", name, err)
+		}
+	case <-ctx.Done():
+	}
+}
+
+// worker adapts a function running until its context is cancelled, eg. a poller,
+// into a Component whose Stop waits for the function to return
+type worker struct {
+	name   string
+	run    func(ctx context.Context)
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newWorker(name string, run func(ctx context.Context)) *worker {
+	return &worker{name: name, run: run}
+}
+
+func (w *worker) Name() string {
+	return w.name
+}
+
+func (w *worker) Start(ctx context.Context) error {
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.done = make(chan struct{})
+	go func() {
+		defer close(w.done)
+		w.run(ctx)
+	}()
+	return nil
+}
+
+func (w *worker) Stop(ctx context.Context) error {
+	w.cancel()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
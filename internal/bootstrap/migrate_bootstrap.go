@@ -0,0 +1,41 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"payment/internal/migrations"
+)
+
+const migrateUsage = "usage: migrate [-dry-run] up|down|status|redo"
+
+// RunMigrate runs the migrate subcommand with args, eg. ["-dry-run", "up"]
+func RunMigrate(ctx context.Context, app *App, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "print the SQL instead of executing it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	runner := migrations.NewRunner(app.PGRepo.GetRepo(), out)
+	runner.DryRun = *dryRun
+
+	switch flags.Arg(0) {
+	case "up":
+		return runner.Up(ctx)
+	case "down":
+		return runner.Down(ctx)
+	case "redo":
+		return runner.Redo(ctx)
+	case "status":
+		return runner.Status(ctx)
+	default:
+		return fmt.Errorf("unknown migrate command %q, %s", flags.Arg(0), migrateUsage)
+	}
+}
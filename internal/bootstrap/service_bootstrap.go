@@ -0,0 +1,26 @@
+package bootstrap
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"payment/internal/repositories"
+	"payment/internal/services"
+	"payment/pkg/core/metrics"
+	"time"
+)
+
+// paymentMetricsTimeout bounds the payments by status count run on every scrape
+const paymentMetricsTimeout = 5 * time.Second
+
+// InitServices builds the business services shared by the gRPC and HTTP servers
+func InitServices(app *App) {
+	gw := NewGateway(app.Config)
+
+	paymentRepo := repositories.NewPaymentRepository(app.PGRepo)
+	refundRepo := repositories.NewRefundRepository(app.PGRepo)
+
+	app.PaymentService = services.NewPaymentService(repositories.NewTracedPaymentRepository(paymentRepo), gw, app.Config.IdempotencyKeyTTL)
+	app.RefundService = services.NewRefundService(repositories.NewTracedRefundRepository(refundRepo), gw)
+	app.WebhookService = services.NewWebhookService(repositories.NewWebhookRepository(app.PGRepo))
+
+	prometheus.MustRegister(metrics.NewPaymentStatusCollector(paymentRepo.CountByStatus, paymentMetricsTimeout))
+}
@@ -0,0 +1,41 @@
+package bootstrap
+
+import (
+	"context"
+	"payment/pkg/core/tracing"
+	"payment/pkg/http/utils"
+)
+
+// NewTracing installs the tracer provider configured by TRACING_EXPORTER. It is started
+// first so its Stop, run last, flushes the spans of everything that drained before.
+func NewTracing(app *App) Component {
+	return &tracingComponent{cfg: tracing.Config{
+		ServiceName: utils.APPNAME,
+		Exporter:    app.Config.TracingExporter,
+		Endpoint:    app.Config.TracingEndpoint,
+		Insecure:    app.Config.TracingInsecure,
+		SampleRatio: app.Config.TracingSampleRatio,
+	}}
+}
+
+type tracingComponent struct {
+	cfg      tracing.Config
+	shutdown func(context.Context) error
+}
+
+func (t *tracingComponent) Name() string {
+	return "tracing"
+}
+
+func (t *tracingComponent) Start(ctx context.Context) error {
+	shutdown, err := tracing.Init(ctx, t.cfg)
+	if err != nil {
+		return err
+	}
+	t.shutdown = shutdown
+	return nil
+}
+
+func (t *tracingComponent) Stop(ctx context.Context) error {
+	return t.shutdown(ctx)
+}
@@ -0,0 +1,91 @@
+package bootstrap
+
+import (
+	"payment/internal/repositories"
+	"payment/internal/workers"
+	"payment/pkg/core/kafka/payment"
+)
+
+// NewOutboxRelay publishes the outbox through producer, it must be stopped before the
+// producer is closed
+func NewOutboxRelay(app *App, producer *payment.Producer) Component {
+	relay := workers.NewOutboxRelay(
+		repositories.NewOutboxRepository(app.PGRepo),
+		producer,
+		workers.OutboxRelayConfig{
+			PollInterval: app.Config.OutboxPollInterval,
+			BatchSize:    app.Config.OutboxBatchSize,
+			MaxAttempts:  app.Config.OutboxMaxAttempts,
+			BaseBackoff:  app.Config.OutboxBaseBackoff,
+			MaxBackoff:   app.Config.OutboxMaxBackoff,
+			Lease:        app.Config.OutboxLease,
+		},
+	)
+
+	return newWorker("outbox relay", relay.Run)
+}
+
+// NewPaymentReconciler finalizes stuck payments through the payment service
+func NewPaymentReconciler(app *App) Component {
+	reconciler := workers.NewPaymentReconciler(
+		repositories.NewPaymentRepository(app.PGRepo),
+		app.PaymentService,
+		workers.PaymentReconcilerConfig{
+			PollInterval: app.Config.ReconcilerPollInterval,
+			StuckAfter:   app.Config.ReconcilerStuckAfter,
+			BatchSize:    app.Config.ReconcilerBatchSize,
+			MaxAttempts:  app.Config.ReconcilerMaxAttempts,
+			BaseBackoff:  app.Config.ReconcilerBaseBackoff,
+			MaxBackoff:   app.Config.ReconcilerMaxBackoff,
+		},
+	)
+
+	return newWorker("payment reconciler", reconciler.Run)
+}
+
+// NewRefundReconciler finalizes stuck refunds through the refund service
+func NewRefundReconciler(app *App) Component {
+	reconciler := workers.NewRefundReconciler(
+		repositories.NewRefundRepository(app.PGRepo),
+		app.RefundService,
+		workers.RefundReconcilerConfig{
+			PollInterval: app.Config.RefundReconcilerPollInterval,
+			StuckAfter:   app.Config.RefundReconcilerStuckAfter,
+			BatchSize:    app.Config.RefundReconcilerBatchSize,
+		},
+	)
+
+	return newWorker("refund reconciler", reconciler.Run)
+}
+
+// NewAuthorizationExpirer expires authorizations that were not captured in time
+func NewAuthorizationExpirer(app *App) Component {
+	expirer := workers.NewAuthorizationExpirer(
+		repositories.NewPaymentRepository(app.PGRepo),
+		app.PaymentService,
+		workers.AuthorizationExpirerConfig{
+			PollInterval: app.Config.ExpiryPollInterval,
+			TTL:          app.Config.AuthorizationTTL,
+			BatchSize:    app.Config.ExpiryBatchSize,
+		},
+	)
+
+	return newWorker("authorization expirer", expirer.Run)
+}
+
+// NewWebhookDispatcher delivers merchant webhooks
+func NewWebhookDispatcher(app *App) Component {
+	dispatcher := workers.NewWebhookDispatcher(
+		repositories.NewWebhookRepository(app.PGRepo),
+		workers.WebhookDispatcherConfig{
+			PollInterval: app.Config.WebhookPollInterval,
+			BatchSize:    app.Config.WebhookBatchSize,
+			MaxAttempts:  app.Config.WebhookMaxAttempts,
+			BaseBackoff:  app.Config.WebhookBaseBackoff,
+			MaxBackoff:   app.Config.WebhookMaxBackoff,
+			Timeout:      app.Config.WebhookTimeout,
+		},
+	)
+
+	return newWorker("webhook dispatcher", dispatcher.Run)
+}
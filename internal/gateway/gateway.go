@@ -0,0 +1,48 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"payment/pkg/core/money"
+)
+
+var (
+	// ErrTimeout is returned when the gateway did not answer in time. The
+	// outcome of the operation is unknown and the payment must stay retriable.
+	ErrTimeout = errors.New("gateway timeout")
+	// ErrUnknownReference is returned when an operation targets an authorization
+	// the gateway does not know about.
+	ErrUnknownReference = errors.New("gateway reference not found")
+)
+
+// AuthorizeRequest carries the data a gateway needs to place a hold on funds
+type AuthorizeRequest struct {
+	PaymentID string
+	OrderID   string
+	CardToken string
+	Amount    money.Money
+}
+
+// RefundRequest carries a refund of a captured payment. RefundID is the idempotency
+// reference, a gateway refunds at most once per RefundID however often it is retried.
+type RefundRequest struct {
+	RefundID  string
+	Reference string
+	Amount    money.Money
+}
+
+// Result describes the answer of the gateway for a single operation
+type Result struct {
+	Approved  bool
+	Reference string
+	Reason    string
+}
+
+// Gateway is the seam between PaymentService and a payment provider.
+// Real integrations and the in-process simulator both implement it.
+type Gateway interface {
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error)
+	Capture(ctx context.Context, reference string, amount money.Money) (*Result, error)
+	Void(ctx context.Context, reference string) (*Result, error)
+	Refund(ctx context.Context, req RefundRequest) (*Result, error)
+}
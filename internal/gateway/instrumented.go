@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"payment/pkg/core/metrics"
+	"payment/pkg/core/money"
+	"payment/pkg/core/tracing"
+	"time"
+)
+
+// Instrumented records the latency and outcome of every call to the wrapped gateway
+// and traces it as a client span
+type Instrumented struct {
+	next Gateway
+}
+
+func NewInstrumented(next Gateway) *Instrumented {
+	return &Instrumented{next: next}
+}
+
+func (g *Instrumented) Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error) {
+	ctx, span := tracing.StartKind(ctx, "Gateway.Authorize", trace.SpanKindClient)
+	start := time.Now()
+	res, err := g.next.Authorize(ctx, req)
+	observe("authorize", start, res, err)
+	endSpan(span, res, err)
+	return res, err
+}
+
+func (g *Instrumented) Capture(ctx context.Context, reference string, amount money.Money) (*Result, error) {
+	ctx, span := tracing.StartKind(ctx, "Gateway.Capture", trace.SpanKindClient, attribute.String("gateway.reference", reference))
+	start := time.Now()
+	res, err := g.next.Capture(ctx, reference, amount)
+	observe("capture", start, res, err)
+	endSpan(span, res, err)
+	return res, err
+}
+
+func (g *Instrumented) Void(ctx context.Context, reference string) (*Result, error) {
+	ctx, span := tracing.StartKind(ctx, "Gateway.Void", trace.SpanKindClient, attribute.String("gateway.reference", reference))
+	start := time.Now()
+	res, err := g.next.Void(ctx, reference)
+	observe("void", start, res, err)
+	endSpan(span, res, err)
+	return res, err
+}
+
+func (g *Instrumented) Refund(ctx context.Context, req RefundRequest) (*Result, error) {
+	ctx, span := tracing.StartKind(ctx, "Gateway.Refund", trace.SpanKindClient,
+		attribute.String("gateway.reference", req.Reference), attribute.String("refund.id", req.RefundID))
+	start := time.Now()
+	res, err := g.next.Refund(ctx, req)
+	observe("refund", start, res, err)
+	endSpan(span, res, err)
+	return res, err
+}
+
+// observe labels the call approved, declined, timeout or error
+func observe(operation string, start time.Time, res *Result, err error) {
+	outcome := "approved"
+	switch {
+	case errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded):
+		outcome = "timeout"
+	case err != nil:
+		outcome = "error"
+	case res == nil || !res.Approved:
+		outcome = "declined"
+	}
+	metrics.GatewayDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+func endSpan(span trace.Span, res *Result, err error) {
+	if res != nil {
+		span.SetAttributes(attribute.Bool("gateway.approved", res.Approved))
+	}
+	tracing.End(span, err)
+}
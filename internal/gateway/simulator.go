@@ -0,0 +1,131 @@
+package gateway
+
+import (
+	"context"
+	"payment/pkg/core/money"
+	"strings"
+	"time"
+)
+
+// Outcome is the deterministic answer the simulator gives for a matching rule
+type Outcome string
+
+const (
+	OutcomeApprove Outcome = "APPROVE"
+	OutcomeDecline Outcome = "DECLINE"
+	OutcomeTimeout Outcome = "TIMEOUT"
+)
+
+// SimulatorRule matches an authorization by card token or by amount.
+// An empty CardToken matches any token; a zero MinAmount matches any amount.
+// MinAmount is in minor units and compared regardless of currency.
+type SimulatorRule struct {
+	CardToken string
+	MinAmount int64
+	Outcome   Outcome
+	Reason    string
+}
+
+func (r SimulatorRule) matches(req AuthorizeRequest) bool {
+	if r.CardToken != "" && r.CardToken != req.CardToken {
+		return false
+	}
+	if r.MinAmount > 0 && req.Amount.Amount < r.MinAmount {
+		return false
+	}
+	return r.CardToken != "" || r.MinAmount > 0
+}
+
+type SimulatorConfig struct {
+	// Latency is waited before every answer, honouring context cancellation
+	Latency time.Duration
+	// Rules are evaluated in order, the first match wins
+	Rules []SimulatorRule
+	// Default is used when no rule matches
+	Default Outcome
+}
+
+const referencePrefix = "sim_"
+
+// Simulator is an in-process Gateway with deterministic approve/decline/timeout rules
+type Simulator struct {
+	cfg SimulatorConfig
+}
+
+func NewSimulator(cfg SimulatorConfig) *Simulator {
+	if cfg.Default == "" {
+		cfg.Default = OutcomeApprove
+	}
+	return &Simulator{cfg: cfg}
+}
+
+func (s *Simulator) Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	outcome, reason := s.cfg.Default, ""
+	for _, rule := range s.cfg.Rules {
+		if rule.matches(req) {
+			outcome, reason = rule.Outcome, rule.Reason
+			break
+		}
+	}
+
+	switch outcome {
+	case OutcomeTimeout:
+		return nil, ErrTimeout
+	case OutcomeDecline:
+		if reason == "" {
+			reason = "declined by simulator"
+		}
+		return &Result{Approved: false, Reason: reason}, nil
+	}
+
+	return &Result{Approved: true, Reference: referencePrefix + req.PaymentID}, nil
+}
+
+func (s *Simulator) Capture(ctx context.Context, reference string, amount money.Money) (*Result, error) {
+	return s.settle(ctx, reference, amount.Amount)
+}
+
+func (s *Simulator) Void(ctx context.Context, reference string) (*Result, error) {
+	return s.settle(ctx, reference, 0)
+}
+
+// Refund answers with a reference derived from the refund id, as a real gateway would
+// for a repeated refund id
+func (s *Simulator) Refund(ctx context.Context, req RefundRequest) (*Result, error) {
+	res, err := s.settle(ctx, req.Reference, req.Amount.Amount)
+	if err != nil || !res.Approved {
+		return res, err
+	}
+	return &Result{Approved: true, Reference: referencePrefix + "refund_" + req.RefundID}, nil
+}
+
+// settle approves follow-up operations on any reference the simulator issued.
+// Amount checks against the authorization are the caller's responsibility.
+func (s *Simulator) settle(ctx context.Context, reference string, amount int64) (*Result, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(reference, referencePrefix) {
+		return nil, ErrUnknownReference
+	}
+	if amount < 0 {
+		return &Result{Approved: false, Reference: reference, Reason: "amount must not be negative"}, nil
+	}
+	return &Result{Approved: true, Reference: reference}, nil
+}
+
+func (s *Simulator) wait(ctx context.Context) error {
+	if s.cfg.Latency <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(s.cfg.Latency):
+		return nil
+	}
+}
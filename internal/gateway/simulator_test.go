@@ -0,0 +1,114 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"payment/pkg/core/money"
+	"testing"
+	"time"
+)
+
+func TestSimulatorAuthorize(t *testing.T) {
+	cfg := SimulatorConfig{
+		Rules: []SimulatorRule{
+			{CardToken: "tok_decline", Outcome: OutcomeDecline, Reason: "insufficient funds"},
+			{CardToken: "tok_timeout", Outcome: OutcomeTimeout},
+			{CardToken: "tok_plain_decline", Outcome: OutcomeDecline},
+			{MinAmount: 100000, Outcome: OutcomeDecline, Reason: "amount over limit"},
+		},
+	}
+	tests := []struct {
+		name       string
+		cfg        SimulatorConfig
+		req        AuthorizeRequest
+		wantErr    error
+		approved   bool
+		wantReason string
+		wantRef    string
+	}{
+		{name: "default approves", cfg: cfg,
+			req:      AuthorizeRequest{PaymentID: "p-1", CardToken: "tok_ok", Amount: money.Money{Amount: 1000, Currency: "USD"}},
+			approved: true, wantRef: "sim_p-1"},
+		{name: "declined by card", cfg: cfg,
+			req:        AuthorizeRequest{PaymentID: "p-2", CardToken: "tok_decline", Amount: money.Money{Amount: 1000, Currency: "USD"}},
+			wantReason: "insufficient funds"},
+		{name: "decline without reason", cfg: cfg,
+			req:        AuthorizeRequest{PaymentID: "p-3", CardToken: "tok_plain_decline", Amount: money.Money{Amount: 1000, Currency: "USD"}},
+			wantReason: "declined by simulator"},
+		{name: "declined by amount", cfg: cfg,
+			req:        AuthorizeRequest{PaymentID: "p-4", CardToken: "tok_ok", Amount: money.Money{Amount: 100000, Currency: "USD"}},
+			wantReason: "amount over limit"},
+		{name: "timeout", cfg: cfg,
+			req:     AuthorizeRequest{PaymentID: "p-5", CardToken: "tok_timeout", Amount: money.Money{Amount: 1000, Currency: "USD"}},
+			wantErr: ErrTimeout},
+		{name: "first matching rule wins", cfg: cfg,
+			req:        AuthorizeRequest{PaymentID: "p-6", CardToken: "tok_decline", Amount: money.Money{Amount: 100000, Currency: "USD"}},
+			wantReason: "insufficient funds"},
+		{name: "default outcome", cfg: SimulatorConfig{Default: OutcomeTimeout},
+			req:     AuthorizeRequest{PaymentID: "p-7", Amount: money.Money{Amount: 1000, Currency: "USD"}},
+			wantErr: ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := NewSimulator(tt.cfg).Authorize(context.Background(), tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authorize() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if res.Approved != tt.approved || res.Reason != tt.wantReason || res.Reference != tt.wantRef {
+				t.Errorf("Authorize() = %+v, want approved %v reason %q reference %q", res, tt.approved, tt.wantReason, tt.wantRef)
+			}
+		})
+	}
+}
+
+func TestSimulatorHonoursCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	sim := NewSimulator(SimulatorConfig{Latency: time.Minute})
+	if _, err := sim.Authorize(ctx, AuthorizeRequest{PaymentID: "p-1"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Authorize() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSimulatorSettle(t *testing.T) {
+	sim := NewSimulator(SimulatorConfig{})
+	usd := func(amount int64) money.Money { return money.Money{Amount: amount, Currency: "USD"} }
+
+	tests := []struct {
+		name     string
+		settle   func() (*Result, error)
+		wantErr  error
+		approved bool
+		wantRef  string
+	}{
+		{name: "capture", settle: func() (*Result, error) { return sim.Capture(context.Background(), "sim_p-1", usd(500)) },
+			approved: true, wantRef: "sim_p-1"},
+		{name: "negative capture", settle: func() (*Result, error) { return sim.Capture(context.Background(), "sim_p-1", usd(-1)) },
+			wantRef: "sim_p-1"},
+		{name: "void", settle: func() (*Result, error) { return sim.Void(context.Background(), "sim_p-1") },
+			approved: true, wantRef: "sim_p-1"},
+		{name: "unknown reference", settle: func() (*Result, error) { return sim.Void(context.Background(), "other_p-1") },
+			wantErr: ErrUnknownReference},
+		{name: "refund", settle: func() (*Result, error) {
+			return sim.Refund(context.Background(), RefundRequest{RefundID: "r-1", Reference: "sim_p-1", Amount: usd(200)})
+		}, approved: true, wantRef: "sim_refund_r-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.settle()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if res.Approved != tt.approved || res.Reference != tt.wantRef {
+				t.Errorf("result = %+v, want approved %v reference %q", res, tt.approved, tt.wantRef)
+			}
+		})
+	}
+}
@@ -4,17 +4,24 @@ import (
 	"context"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	model "payment/internal/models"
 	"payment/internal/services"
+	"payment/pkg/http/paging"
+	"payment/pkg/http/utils"
+	"payment/pkg/http/utils/app_errors"
 	pb "payment/pkg/proto/paymentpb"
 )
 
 type PaymentHandler struct {
 	pb.UnimplementedPaymentServiceServer
-	svc services.PaymentProcessor
+	svc       services.PaymentProcessor
+	reader    services.PaymentReader
+	refundSvc services.RefundProcessor
 }
 
-func NewPaymentHandler(s services.PaymentProcessor) *PaymentHandler {
-	return &PaymentHandler{svc: s}
+func NewPaymentHandler(s services.PaymentProcessor, reader services.PaymentReader, r services.RefundProcessor) *PaymentHandler {
+	return &PaymentHandler{svc: s, reader: reader, refundSvc: r}
 }
 
 func (h *PaymentHandler) Pay(ctx context.Context, req *pb.PayRequest) (*pb.PayResponse, error) {
@@ -27,11 +34,156 @@ func (h *PaymentHandler) Pay(ctx context.Context, req *pb.PayRequest) (*pb.PayRe
 	// call business logic
 	resp, err := h.svc.Process(ctx, req)
 	if err != nil {
-		// translate or log as needed; here return response with error text
-		if resp == nil {
-			return nil, status.Errorf(codes.Internal, "payment processing failed: %v", err)
-		}
-		return resp, nil
+		return nil, app_errors.ToGRPC(err)
 	}
 	return resp, nil
 }
+
+func (h *PaymentHandler) Capture(ctx context.Context, req *pb.CaptureRequest) (*pb.CaptureResponse, error) {
+
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "request is nil")
+	}
+
+	resp, err := h.svc.Capture(ctx, req)
+	if err != nil {
+		return nil, app_errors.ToGRPC(err)
+	}
+	return resp, nil
+}
+
+func (h *PaymentHandler) Void(ctx context.Context, req *pb.VoidRequest) (*pb.VoidResponse, error) {
+
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "request is nil")
+	}
+
+	resp, err := h.svc.Void(ctx, req)
+	if err != nil {
+		return nil, app_errors.ToGRPC(err)
+	}
+	return resp, nil
+}
+
+func (h *PaymentHandler) Refund(ctx context.Context, req *pb.RefundRequest) (*pb.RefundResponse, error) {
+
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "request is nil")
+	}
+
+	resp, err := h.refundSvc.Refund(ctx, req)
+	if err != nil {
+		return nil, app_errors.ToGRPC(err)
+	}
+	return resp, nil
+}
+
+func (h *PaymentHandler) GetPayment(ctx context.Context, req *pb.GetPaymentRequest) (*pb.Payment, error) {
+
+	if req == nil || (req.PaymentId == "" && req.IdempotencyKey == "") {
+		return nil, app_errors.ToGRPC(app_errors.Validation(app_errors.FieldViolation{
+			Field:       "payment_id",
+			Description: "payment_id or idempotency_key is required",
+		}))
+	}
+
+	var (
+		payment *model.Payment
+		err     error
+	)
+	if req.PaymentId != "" {
+		payment, err = h.reader.GetPayment(ctx, req.PaymentId)
+	} else {
+		payment, err = h.reader.GetPaymentByIdempotencyKey(ctx, req.IdempotencyKey)
+	}
+	if err != nil {
+		return nil, app_errors.ToGRPC(err)
+	}
+	return toPBPayment(payment), nil
+}
+
+func (h *PaymentHandler) ListPayments(ctx context.Context, req *pb.ListPaymentsRequest) (*pb.ListPaymentsResponse, error) {
+
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "request is nil")
+	}
+
+	filter := &model.PaymentFilter{
+		OrderID: req.OrderId,
+		Status:  req.Status,
+	}
+	if req.CreatedFrom != nil {
+		filter.CreateFrom = utils.ToPointer(req.CreatedFrom.AsTime())
+	}
+	if req.CreatedTo != nil {
+		filter.CreateTo = utils.ToPointer(req.CreatedTo.AsTime())
+	}
+	pager := &paging.Pager{
+		Page:     int(req.Page),
+		PageSize: int(req.PageSize),
+		Sort:     req.Sort,
+	}
+
+	payments, err := h.reader.ListPayments(ctx, filter, pager)
+	if err != nil {
+		return nil, app_errors.ToGRPC(err)
+	}
+
+	resp := &pb.ListPaymentsResponse{
+		Payments: make([]*pb.Payment, 0, len(payments)),
+		Total:    pager.TotalRows,
+		Page:     int32(pager.GetPage()),
+		PageSize: int32(pager.GetPageSize()),
+	}
+	for i := range payments {
+		resp.Payments = append(resp.Payments, toPBPayment(&payments[i]))
+	}
+	return resp, nil
+}
+
+func (h *PaymentHandler) GetPaymentHistory(ctx context.Context, req *pb.GetPaymentHistoryRequest) (*pb.GetPaymentHistoryResponse, error) {
+
+	if req == nil || req.PaymentId == "" {
+		return nil, app_errors.ToGRPC(app_errors.Validation(app_errors.FieldViolation{
+			Field:       "payment_id",
+			Description: "is required",
+		}))
+	}
+
+	history, err := h.reader.GetPaymentHistory(ctx, req.PaymentId)
+	if err != nil {
+		return nil, app_errors.ToGRPC(err)
+	}
+
+	resp := &pb.GetPaymentHistoryResponse{
+		PaymentId: req.PaymentId,
+		History:   make([]*pb.PaymentStatusChange, 0, len(history)),
+	}
+	for _, c := range history {
+		resp.History = append(resp.History, &pb.PaymentStatusChange{
+			FromStatus: string(c.FromStatus),
+			ToStatus:   string(c.ToStatus),
+			Reason:     c.Reason,
+			Actor:      c.Actor,
+			RequestId:  c.RequestID,
+			CreatedAt:  timestamppb.New(c.CreatedAt),
+		})
+	}
+	return resp, nil
+}
+
+func toPBPayment(p *model.Payment) *pb.Payment {
+	return &pb.Payment{
+		PaymentId:      p.ID.String(),
+		OrderId:        p.OrderID,
+		IdempotencyKey: p.IdempotencyKey,
+		Amount:         &pb.Money{Amount: p.Amount, Currency: p.Currency},
+		CapturedAmount: &pb.Money{Amount: p.CapturedAmount, Currency: p.Currency},
+		RefundedAmount: &pb.Money{Amount: p.RefundedAmount, Currency: p.Currency},
+		Status:         string(p.Status),
+		Attempts:       int32(p.Attempts),
+		LastError:      p.LastError,
+		CreatedAt:      timestamppb.New(p.CreatedAt),
+		UpdatedAt:      timestamppb.New(p.UpdatedAt),
+	}
+}
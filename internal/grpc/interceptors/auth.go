@@ -0,0 +1,102 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"payment/pkg/http/utils"
+	"strings"
+)
+
+const (
+	authorizationKey = "authorization"
+	bearerPrefix     = "Bearer "
+)
+
+// Auth validates the JWT bearer token sent in the authorization metadata with secret.
+// The token role becomes the actor recorded by the payment history. Methods listed in
+// skip, eg. health checks, are served without a token and methods given a role with
+// Require only accept tokens of that role.
+type Auth struct {
+	secret []byte
+	skip   map[string]bool
+	roles  map[string]string
+}
+
+func NewAuth(secret string, skip ...string) *Auth {
+	a := &Auth{secret: []byte(secret), skip: make(map[string]bool, len(skip)), roles: make(map[string]string)}
+	for _, method := range skip {
+		a.skip[method] = true
+	}
+	return a
+}
+
+// Require restricts methods to tokens whose role claim is role, eg. the RPCs moving money
+func (a *Auth) Require(role string, methods ...string) *Auth {
+	for _, method := range methods {
+		a.roles[method] = role
+	}
+	return a
+}
+
+func (a *Auth) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if a.skip[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *Auth) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if a.skip[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, withStreamContext(ss, ctx))
+	}
+}
+
+func (a *Auth) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if len(a.secret) == 0 {
+		return ctx, status.Error(codes.Unauthenticated, "authentication is not configured")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationKey)
+	if len(values) == 0 || !strings.HasPrefix(values[0], bearerPrefix) {
+		return ctx, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimPrefix(values[0], bearerPrefix), claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return a.secret, nil
+	})
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, "fail to authenticate")
+	}
+
+	role, _ := claims["role"].(string)
+	if required, ok := a.roles[method]; ok && role != required {
+		return ctx, status.Error(codes.PermissionDenied, "you are not authorized to perform this action")
+	}
+	if role != "" {
+		ctx = utils.WithActor(ctx, role)
+	}
+	return ctx, nil
+}
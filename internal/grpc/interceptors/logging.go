@@ -0,0 +1,46 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"payment/pkg/core/logger"
+	"time"
+)
+
+// LoggingUnary logs the method, latency and status code of every call
+func LoggingUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// LoggingStream is the streaming counterpart of LoggingUnary
+func LoggingStream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	log := logger.WithCtx(ctx, "gRPC").WithField("method", method).
+		WithField("latency", time.Since(start)).
+		WithField("code", code.String())
+
+	switch code {
+	case codes.OK:
+		log.Info("request completed")
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		logger.LogError(log, err, "request failed")
+	default:
+		log.WithError(err).Warn("request rejected")
+	}
+}
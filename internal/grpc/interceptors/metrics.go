@@ -0,0 +1,33 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"payment/pkg/core/metrics"
+	"time"
+)
+
+// MetricsUnary observes the latency of every call by method and status code
+func MetricsUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observeCall(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// MetricsStream is the streaming counterpart of MetricsUnary
+func MetricsStream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observeCall(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func observeCall(method string, start time.Time, err error) {
+	metrics.GRPCDuration.WithLabelValues(method, status.Code(err).String()).Observe(time.Since(start).Seconds())
+}
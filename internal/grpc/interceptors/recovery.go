@@ -0,0 +1,42 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"payment/pkg/core/logger"
+	"runtime/debug"
+)
+
+// RecoveryUnary turns a panic in a handler into a codes.Internal error instead of
+// crashing the process
+func RecoveryUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStream is the streaming counterpart of RecoveryUnary
+func RecoveryStream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, method string, r interface{}) error {
+	log := logger.WithCtx(ctx, "gRPC|Recovery").WithField("method", method).WithField("stack", string(debug.Stack()))
+	logger.LogError(log, fmt.Errorf("%v", r), "panic in gRPC handler")
+	return status.Error(codes.Internal, "internal error")
+}
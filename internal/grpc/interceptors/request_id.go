@@ -0,0 +1,43 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"payment/pkg/http/utils"
+	"time"
+)
+
+// RequestIDUnary reads x-request-id from the incoming metadata, or generates one, stores it
+// in the context read by logger.WithCtx and echoes it back in the response header
+func RequestIDUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(withRequestID(ctx), req)
+	}
+}
+
+// RequestIDStream is the streaming counterpart of RequestIDUnary
+func RequestIDStream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, withStreamContext(ss, withRequestID(ss.Context())))
+	}
+}
+
+func withRequestID(ctx context.Context) context.Context {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(utils.HeaderXRequestID); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = generateRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(utils.HeaderXRequestID, requestID))
+	return context.WithValue(ctx, utils.HeaderXRequestID, requestID)
+}
+
+func generateRequestID() string {
+	return fmt.Sprintf("%d", time.Now().UnixNano())
+}
@@ -0,0 +1,21 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+)
+
+// serverStream overrides the context of a grpc.ServerStream so stream interceptors
+// can hand values down to the handler like unary interceptors do
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func withStreamContext(ss grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+	return &serverStream{ServerStream: ss, ctx: ctx}
+}
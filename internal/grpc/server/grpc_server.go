@@ -2,11 +2,18 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/encoding/protojson"
 	"log"
 	"net"
 	"net/http"
+	"payment/pkg/core/tracing"
 	pb "payment/pkg/proto/paymentpb"
 )
 
@@ -15,56 +22,137 @@ type GRPCServer struct {
 	grpcAddr   string
 	httpAddr   string
 	httpServer *http.Server
-	lis        net.Listener
+	cancel     context.CancelFunc
+	failed     chan error
 }
 
-func NewGRPCServer(handler pb.PaymentServiceServer, grpcAddr, httpAddr string) *GRPCServer {
-	s := grpc.NewServer()
+func NewGRPCServer(handler pb.PaymentServiceServer, grpcAddr, httpAddr string, opts ...grpc.ServerOption) *GRPCServer {
+	s := grpc.NewServer(opts...)
 	pb.RegisterPaymentServiceServer(s, handler)
 	return &GRPCServer{
 		server:   s,
 		grpcAddr: grpcAddr,
 		httpAddr: httpAddr,
+		failed:   make(chan error, 2),
 	}
 }
 
-// Run starts the gRPC server and the grpc-gateway HTTP server.
-// Cancel the provided ctx to trigger graceful shutdown.
-func (s *GRPCServer) Run(ctx context.Context) error {
-	// start gRPC listener
-	lis, err := net.Listen("tcp", s.grpcAddr)
+// RegisterHealth serves the grpc.health.v1 service, it must be called before Start
+func (s *GRPCServer) RegisterHealth(srv healthpb.HealthServer) {
+	healthpb.RegisterHealthServer(s.server, srv)
+}
+
+func (s *GRPCServer) Name() string {
+	return "grpc server"
+}
+
+// Start listens on both addresses and serves the gRPC server and the grpc-gateway in the
+// background, errors while serving are reported on Failed. Nothing keeps running when
+// Start fails.
+func (s *GRPCServer) Start(ctx context.Context) error {
+	grpcLis, err := net.Listen("tcp", s.grpcAddr)
 	if err != nil {
 		return err
 	}
-	s.lis = lis
+	httpLis, err := net.Listen("tcp", s.httpAddr)
+	if err != nil {
+		_ = grpcLis.Close()
+		return err
+	}
+
+	// The gateway connection to the gRPC server lives until Stop
+	ctx, s.cancel = context.WithCancel(ctx)
+	httpServer, err := s.newGateway(ctx)
+	if err != nil {
+		s.cancel()
+		_ = grpcLis.Close()
+		_ = httpLis.Close()
+		return fmt.Errorf("grpc-gateway setup error: %w", err)
+	}
+	s.httpServer = httpServer
 
-	grpcErrCh := make(chan error, 1)
 	go func() {
 		log.Printf("gRPC server running on %s", s.grpcAddr)
-		if err := s.server.Serve(lis); err != nil {
-			grpcErrCh <- err
+		if err := s.server.Serve(grpcLis); err != nil {
+			s.failed <- fmt.Errorf("gRPC server error: %w", err)
+		}
+	}()
+	go func() {
+		log.Printf("grpc-gateway running on %s", s.httpAddr)
+		if err := httpServer.Serve(httpLis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.failed <- fmt.Errorf("grpc-gateway server error: %w", err)
 		}
 	}()
+	return nil
+}
 
-	// wait for cancel or server error
-	select {
-	case <-ctx.Done():
-		log.Println("shutting down servers")
-		// Graceful shutdown of HTTP and gRPC
-		_ = s.httpServer.Shutdown(context.Background())
-		s.server.GracefulStop()
-		return ctx.Err()
-	case err := <-grpcErrCh:
-		return fmt.Errorf("gRPC server error: %w", err)
+// Failed reports errors of the servers after Start
+func (s *GRPCServer) Failed() <-chan error {
+	return s.failed
+}
+
+// newGateway builds the REST proxy. JSON uses the proto field names so payloads
+// look like the rest of the HTTP API (eg. payment_id). W3C trace headers of REST calls
+// are forwarded to the gRPC server as metadata.
+func (s *GRPCServer) newGateway(ctx context.Context) (*http.Server, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames:   true,
+				EmitUnpopulated: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		}),
+	)
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	}
+	if err := pb.RegisterPaymentServiceHandlerFromEndpoint(ctx, mux, dialAddr(s.grpcAddr), opts); err != nil {
+		return nil, err
+	}
+
+	return &http.Server{Addr: s.httpAddr, Handler: tracing.ExtractHTTP(mux)}, nil
+}
+
+// dialAddr turns a listen address such as ":50052" into one the gateway can dial
+func dialAddr(listenAddr string) string {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil || host == "" || host == "0.0.0.0" || host == "::" {
+		return net.JoinHostPort("localhost", port)
 	}
+	return listenAddr
 }
 
-// Stop triggers an immediate graceful shutdown.
-func (s *GRPCServer) Stop() {
+// Stop drains the gateway first, its calls go through the gRPC server, then waits for
+// in-flight gRPC calls. Calls still running once ctx is done are cancelled.
+func (s *GRPCServer) Stop(ctx context.Context) error {
+	var errs []error
 	if s.httpServer != nil {
-		_ = s.httpServer.Shutdown(context.Background())
+		if err := s.httpServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("grpc-gateway shutdown: %w", err))
+		}
 	}
-	if s.server != nil {
+
+	done := make(chan struct{})
+	go func() {
 		s.server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// Watch streams of health probes never end on their own
+		s.server.Stop()
+		<-done
+		errs = append(errs, fmt.Errorf("gRPC graceful stop: %w", ctx.Err()))
+	}
+
+	if s.cancel != nil {
+		s.cancel()
 	}
+	return errors.Join(errs...)
 }
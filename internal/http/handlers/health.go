@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"payment/pkg/core/health"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Liveness only tells the process is serving requests. Dependencies are left to
+// Readiness so an outage of Postgres or Kafka does not get every instance restarted.
+func (h *HealthHandler) Liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readiness serves the last dependency report, 503 while a dependency is down, before
+// the first checks ran and during shutdown
+func (h *HealthHandler) Readiness(ctx *gin.Context) {
+	report := h.checker.Report()
+	code := http.StatusOK
+	if !report.Ready() {
+		code = http.StatusServiceUnavailable
+	}
+	ctx.JSON(code, report)
+}
@@ -1 +1,122 @@
 package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	model "payment/internal/models"
+	"payment/internal/services"
+	"payment/pkg/core/logger"
+	"payment/pkg/http/paging"
+	"payment/pkg/http/utils"
+	"payment/pkg/http/utils/app_errors"
+	pb "payment/pkg/proto/paymentpb"
+)
+
+type PaymentHandler struct {
+	reader    services.PaymentReader
+	refundSvc services.RefundProcessor
+}
+
+func NewPaymentHandler(reader services.PaymentReader, refundSvc services.RefundProcessor) *PaymentHandler {
+	return &PaymentHandler{reader: reader, refundSvc: refundSvc}
+}
+
+func (h *PaymentHandler) GetPayment(ctx *gin.Context) {
+	payment, err := h.reader.GetPayment(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &model.PaymentResponse{
+		Meta: utils.NewMetaData(ctx.Request.Context()),
+		Data: model.NewPaymentResponseData(payment),
+	})
+}
+
+func (h *PaymentHandler) GetPaymentByIdempotencyKey(ctx *gin.Context) {
+	payment, err := h.reader.GetPaymentByIdempotencyKey(ctx.Request.Context(), ctx.Param("key"))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &model.PaymentResponse{
+		Meta: utils.NewMetaData(ctx.Request.Context()),
+		Data: model.NewPaymentResponseData(payment),
+	})
+}
+
+func (h *PaymentHandler) ListPayments(ctx *gin.Context) {
+	log := logger.WithCtx(ctx, "PaymentHandler|ListPayments")
+
+	var filter model.PaymentFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		logger.LogError(log, err, "invalid payment filter")
+		_ = ctx.Error(app_errors.AppError(err.Error(), app_errors.StatusValidationError))
+		return
+	}
+
+	pager := paging.NewPagerWithGinCtx(ctx)
+	if pager == nil {
+		_ = ctx.Error(app_errors.AppError("invalid paging parameters", app_errors.StatusValidationError))
+		return
+	}
+
+	payments, err := h.reader.ListPayments(ctx.Request.Context(), &filter, pager)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	data := make([]model.PaymentResponseData, 0, len(payments))
+	for i := range payments {
+		data = append(data, model.NewPaymentResponseData(&payments[i]))
+	}
+
+	ctx.JSON(http.StatusOK, paging.NewBodyPaginated(ctx.Request.Context(), data, pager))
+}
+
+func (h *PaymentHandler) Refund(ctx *gin.Context) {
+	log := logger.WithCtx(ctx, "PaymentHandler|Refund")
+
+	var req model.CreateRefundRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(log, err, "invalid refund request body")
+		_ = ctx.Error(app_errors.AppError(err.Error(), app_errors.StatusValidationError))
+		return
+	}
+
+	resp, err := h.refundSvc.Refund(ctx.Request.Context(), &pb.RefundRequest{
+		PaymentId:      ctx.Param("id"),
+		IdempotencyKey: req.IdempotencyKey,
+		Amount:         &pb.Money{Amount: req.Amount, Currency: req.Currency},
+		Reason:         req.Reason,
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &model.CreateRefundResponse{
+		Meta: utils.NewMetaData(ctx.Request.Context()),
+		Data: model.CreateRefundResponseData{
+			RefundID:       resp.RefundId,
+			PaymentID:      resp.PaymentId,
+			Amount:         resp.GetAmount().GetAmount(),
+			RefundedAmount: resp.GetRefundedAmount().GetAmount(),
+			Currency:       resp.GetAmount().GetCurrency(),
+			Status:         resp.Status,
+		},
+	})
+}
+
+// abortWithError hands err to app_errors.ErrorHandler, hiding errors that are not application errors
+func abortWithError(ctx *gin.Context, err error) {
+	var appErr *app_errors.ResponseError
+	if !errors.As(err, &appErr) {
+		appErr = app_errors.Internal()
+	}
+	_ = ctx.Error(appErr)
+}
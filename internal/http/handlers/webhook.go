@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	model "payment/internal/models"
+	"payment/internal/services"
+	"payment/pkg/core/logger"
+	"payment/pkg/http/paging"
+	"payment/pkg/http/utils"
+	"payment/pkg/http/utils/app_errors"
+)
+
+type WebhookHandler struct {
+	svc services.WebhookManager
+}
+
+func NewWebhookHandler(svc services.WebhookManager) *WebhookHandler {
+	return &WebhookHandler{svc: svc}
+}
+
+// RegisterEndpoint stores a webhook endpoint, the response is the only one carrying its secret
+func (h *WebhookHandler) RegisterEndpoint(ctx *gin.Context) {
+	log := logger.WithCtx(ctx, "WebhookHandler|RegisterEndpoint")
+
+	var req model.CreateWebhookEndpointRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(log, err, "invalid webhook endpoint body")
+		_ = ctx.Error(app_errors.AppError(err.Error(), app_errors.StatusValidationError))
+		return
+	}
+
+	endpoint, err := h.svc.RegisterEndpoint(ctx.Request.Context(), &req)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	data := model.NewWebhookEndpointResponseData(endpoint)
+	data.Secret = endpoint.Secret
+	ctx.JSON(http.StatusCreated, &model.WebhookEndpointResponse{
+		Meta: utils.NewMetaData(ctx.Request.Context()),
+		Data: data,
+	})
+}
+
+func (h *WebhookHandler) ListEndpoints(ctx *gin.Context) {
+	pager := paging.NewPagerWithGinCtx(ctx)
+	if pager == nil {
+		_ = ctx.Error(app_errors.AppError("invalid paging parameters", app_errors.StatusValidationError))
+		return
+	}
+
+	endpoints, err := h.svc.ListEndpoints(ctx.Request.Context(), ctx.Query("merchant_id"), pager)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	data := make([]model.WebhookEndpointResponseData, 0, len(endpoints))
+	for i := range endpoints {
+		data = append(data, model.NewWebhookEndpointResponseData(&endpoints[i]))
+	}
+	ctx.JSON(http.StatusOK, paging.NewBodyPaginated(ctx.Request.Context(), data, pager))
+}
+
+func (h *WebhookHandler) DeleteEndpoint(ctx *gin.Context) {
+	if err := h.svc.DeleteEndpoint(ctx.Request.Context(), ctx.Query("merchant_id"), ctx.Param("id")); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) ListDeliveries(ctx *gin.Context) {
+	pager := paging.NewPagerWithGinCtx(ctx)
+	if pager == nil {
+		_ = ctx.Error(app_errors.AppError("invalid paging parameters", app_errors.StatusValidationError))
+		return
+	}
+
+	deliveries, err := h.svc.ListDeliveries(ctx.Request.Context(), ctx.Query("merchant_id"), ctx.Param("id"), pager)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	data := make([]model.WebhookDeliveryResponseData, 0, len(deliveries))
+	for i := range deliveries {
+		data = append(data, model.NewWebhookDeliveryResponseData(&deliveries[i]))
+	}
+	ctx.JSON(http.StatusOK, paging.NewBodyPaginated(ctx.Request.Context(), data, pager))
+}
+
+// ReplayDelivery queues a delivery to be sent again, the dispatcher picks it up on its next poll
+func (h *WebhookHandler) ReplayDelivery(ctx *gin.Context) {
+	delivery, err := h.svc.ReplayDelivery(ctx.Request.Context(), ctx.Query("merchant_id"), ctx.Param("id"), ctx.Param("delivery_id"))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, &model.WebhookDeliveryResponse{
+		Meta: utils.NewMetaData(ctx.Request.Context()),
+		Data: model.NewWebhookDeliveryResponseData(delivery),
+	})
+}
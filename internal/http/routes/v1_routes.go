@@ -6,22 +6,33 @@ import (
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"payment/internal/bootstrap"
+	"payment/internal/http/handlers"
 	"payment/internal/http/server"
+	"payment/pkg/core/metrics"
 	"payment/pkg/http/middlewares"
 	"payment/pkg/http/utils"
 	"payment/pkg/http/utils/app_errors"
 )
 
 func NewHTTPServer(router *gin.Engine, configCors cors.Config, app *bootstrap.App) {
+	// Probes are registered ahead of the middlewares below so they are not traced or measured
+	server.HealthRoutes(router, handlers.NewHealthHandler(app.Health))
+
 	router.Use(cors.New(configCors))
 	router.Use(middlewares.RequestIDMiddleware())
+	router.Use(middlewares.Tracing())
 	router.Use(middlewares.RequestLogger(utils.APPNAME))
+	router.Use(middlewares.Metrics())
 	router.Use(app_errors.ErrorHandler)
 
 	server.ApplicationV1Router(
-		app.PGRepo,
+		app.PaymentService,
+		app.RefundService,
+		app.WebhookService,
 		router,
+		middlewares.AuthMiddleware(app.Config.JWTAccessSecure),
 	)
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router.GET("/metrics", metrics.GinHandler())
 }
@@ -5,26 +5,54 @@ import (
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	handlers2 "payment/internal/http/handlers"
-	pgGorm "payment/internal/repositories/pg-gorm"
+	"payment/internal/services"
 )
 
 func ApplicationV1Router(
-	newPgRepo pgGorm.PGInterface,
+	paymentReader services.PaymentReader,
+	refundSvc services.RefundProcessor,
+	webhookSvc services.WebhookManager,
 	router *gin.Engine,
+	auth gin.HandlerFunc,
 ) {
 	routerV1 := router.Group("/v1")
 	{
 		// Swagger
 		routerV1.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-		// Migrations
-		MigrateRoutes(routerV1, handlers2.NewMigrationHandler(newPgRepo))
+		// Payments
+		PaymentRoutes(routerV1, handlers2.NewPaymentHandler(paymentReader, refundSvc), auth)
+
+		// Webhooks
+		WebhookRoutes(routerV1, handlers2.NewWebhookHandler(webhookSvc), auth)
 	}
 }
 
-func MigrateRoutes(router *gin.RouterGroup, handler *handlers2.MigrationHandler) {
-	routerAuth := router.Group("/internal")
+// PaymentRoutes serves the payment API, every route needs a token as over gRPC
+func PaymentRoutes(router *gin.RouterGroup, handler *handlers2.PaymentHandler, auth gin.HandlerFunc) {
+	routerPayment := router.Group("/payments", auth)
 	{
-		routerAuth.POST("/migrate", handler.Migrate)
+		routerPayment.GET("", handler.ListPayments)
+		routerPayment.GET("/:id", handler.GetPayment)
+		routerPayment.GET("/idempotency/:key", handler.GetPaymentByIdempotencyKey)
+		routerPayment.POST("/:id/refunds", handler.Refund)
 	}
 }
+
+// WebhookRoutes serves the webhook API, every route is scoped to the merchant_id it is given
+func WebhookRoutes(router *gin.RouterGroup, handler *handlers2.WebhookHandler, auth gin.HandlerFunc) {
+	routerWebhook := router.Group("/webhooks", auth)
+	{
+		routerWebhook.POST("", handler.RegisterEndpoint)
+		routerWebhook.GET("", handler.ListEndpoints)
+		routerWebhook.DELETE("/:id", handler.DeleteEndpoint)
+		routerWebhook.GET("/:id/deliveries", handler.ListDeliveries)
+		routerWebhook.POST("/:id/deliveries/:delivery_id/replay", handler.ReplayDelivery)
+	}
+}
+
+// HealthRoutes serves the probes at the root, outside of the versioned API
+func HealthRoutes(router *gin.Engine, handler *handlers2.HealthHandler) {
+	router.GET("/healthz", handler.Liveness)
+	router.GET("/readyz", handler.Readiness)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	model "payment/internal/models"
+	"payment/internal/services"
+	"payment/pkg/core/kafka"
+	"payment/pkg/core/logger"
+	"payment/pkg/http/utils"
+	"payment/pkg/http/utils/app_errors"
+	pb "payment/pkg/proto/paymentpb"
+)
+
+// orderCreatedActor is recorded in the payment status history for changes driven by order events
+const orderCreatedActor = "kafka:order_created"
+
+type OrderHandler struct {
+	svc services.PaymentProcessor
+}
+
+func NewOrderHandler(s services.PaymentProcessor) *OrderHandler {
+	return &OrderHandler{svc: s}
+}
+
+// HandleOrderCreated drives a payment for an order-created event. It returns nil
+// once the payment reached a durable state, a kafka.Permanent error when the event
+// can never be processed, and any other error when the message must be retried.
+func (h *OrderHandler) HandleOrderCreated(ctx context.Context, data []byte) error {
+	log := logger.WithTag("OrderHandler|HandleOrderCreated")
+
+	var evt model.OrderCreatedEvent
+	if err := json.Unmarshal(data, &evt); err != nil {
+		logger.LogError(log, err, "undecodable order event")
+		return kafka.Permanent(err)
+	}
+	if evt.EventID == "" || evt.OrderID == "" || evt.Amount <= 0 || evt.Currency == "" {
+		err := errors.New("order event is missing event_id, order_id, amount or currency")
+		logger.LogError(log.WithField("event_id", evt.EventID), err, "invalid order event")
+		return kafka.Permanent(err)
+	}
+
+	ctx = utils.WithActor(ctx, orderCreatedActor)
+	resp, err := h.svc.Process(ctx, &pb.PayRequest{
+		EventId:    evt.EventID,
+		OrderId:    evt.OrderID,
+		CustomerId: evt.CustomerID,
+		CardToken:  evt.CardToken,
+		Amount:     &pb.Money{Amount: evt.Amount, Currency: evt.Currency},
+	})
+	if err != nil {
+		var appErr *app_errors.ResponseError
+		if errors.As(err, &appErr) && !appErr.Retryable() {
+			logger.LogError(log.WithField("event_id", evt.EventID), err, "rejected order event")
+			return kafka.Permanent(err)
+		}
+		return err
+	}
+
+	log.WithField("event_id", evt.EventID).Infof("payment %s is %s for order %s", resp.PaymentId, resp.Status, evt.OrderID)
+	return nil
+}
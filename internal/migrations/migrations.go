@@ -0,0 +1,284 @@
+package migrations
+
+import (
+	"fmt"
+	"payment/pkg/core/money"
+)
+
+// Migration is a reversible schema change. Up statements are applied in order and
+// Down statements must undo them, also in order.
+type Migration struct {
+	ID   string
+	Name string
+	Up   []string
+	Down []string
+}
+
+// All returns every migration in the order it must be applied. IDs are timestamps and
+// are never renumbered, databases record them in the gormigrate migrations table.
+func All() []Migration {
+	return []Migration{
+		initialSchema(),
+		moneyMinorUnits(),
+		statusHistory(),
+		idempotencyFingerprint(),
+		eventEnvelope(),
+		webhooks(),
+		outboxTraceParent(),
+		authorizationExpiry(),
+		refundClaim(),
+	}
+}
+
+func initialSchema() Migration {
+	return Migration{
+		ID:   "20220523172948",
+		Name: "create payments",
+		Up: []string{
+			`CREATE SCHEMA IF NOT EXISTS public`,
+			`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`,
+			`CREATE TABLE IF NOT EXISTS payments (
+				id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+				created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+				updated_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+				deleted_at timestamptz,
+				order_id text,
+				idempotency_key text,
+				amount double precision NOT NULL,
+				status text NOT NULL,
+				attempts bigint DEFAULT 0,
+				last_error text
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments (order_id)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS uniq_idem_key ON payments (idempotency_key)`,
+			`CREATE INDEX IF NOT EXISTS idx_payments_status ON payments (status)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS payments`,
+		},
+	}
+}
+
+// moneyMinorUnits stores amounts as bigint minor units of their currency and adds the
+// capture, refund and outbox tables. Legacy rows are assigned money.DefaultCurrency.
+func moneyMinorUnits() Migration {
+	exp, err := money.Exponent(money.DefaultCurrency)
+	if err != nil {
+		panic(err)
+	}
+
+	return Migration{
+		ID:   "20251018090000",
+		Name: "money in minor units, refunds and outbox",
+		Up: []string{
+			fmt.Sprintf(`ALTER TABLE payments ADD COLUMN IF NOT EXISTS currency char(3) NOT NULL DEFAULT '%s'`, money.DefaultCurrency),
+			fmt.Sprintf(`ALTER TABLE payments ALTER COLUMN amount TYPE bigint USING ROUND(amount * POWER(10, %d))::bigint`, exp),
+			`ALTER TABLE payments ADD COLUMN IF NOT EXISTS card_token text`,
+			`ALTER TABLE payments ADD COLUMN IF NOT EXISTS gateway_ref text`,
+			`ALTER TABLE payments ADD COLUMN IF NOT EXISTS captured_amount bigint NOT NULL DEFAULT 0`,
+			`ALTER TABLE payments ADD COLUMN IF NOT EXISTS refunded_amount bigint NOT NULL DEFAULT 0`,
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS refunds (
+				id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+				created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+				updated_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+				deleted_at timestamptz,
+				payment_id uuid NOT NULL,
+				idempotency_key text,
+				amount bigint NOT NULL,
+				currency char(3) NOT NULL DEFAULT '%s',
+				reason text,
+				status text NOT NULL,
+				gateway_ref text,
+				last_error text,
+				CONSTRAINT fk_refunds_payment FOREIGN KEY (payment_id) REFERENCES payments (id)
+			)`, money.DefaultCurrency),
+			`CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds (payment_id)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS uniq_refund_idem_key ON refunds (idempotency_key)`,
+			`CREATE INDEX IF NOT EXISTS idx_refunds_status ON refunds (status)`,
+			`CREATE TABLE IF NOT EXISTS outbox_events (
+				id bigserial PRIMARY KEY,
+				aggregate_key text NOT NULL,
+				event_type text NOT NULL,
+				payload jsonb NOT NULL,
+				status text NOT NULL,
+				attempts bigint DEFAULT 0,
+				last_error text,
+				next_attempt_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+				delivered_at timestamptz,
+				created_at timestamptz DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_outbox_events_aggregate_key ON outbox_events (aggregate_key)`,
+			`CREATE INDEX IF NOT EXISTS idx_outbox_events_status ON outbox_events (status)`,
+			`CREATE INDEX IF NOT EXISTS idx_outbox_events_next_attempt_at ON outbox_events (next_attempt_at)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS outbox_events`,
+			`DROP TABLE IF EXISTS refunds`,
+			`ALTER TABLE payments DROP COLUMN IF EXISTS refunded_amount`,
+			`ALTER TABLE payments DROP COLUMN IF EXISTS captured_amount`,
+			`ALTER TABLE payments DROP COLUMN IF EXISTS gateway_ref`,
+			`ALTER TABLE payments DROP COLUMN IF EXISTS card_token`,
+			fmt.Sprintf(`ALTER TABLE payments ALTER COLUMN amount TYPE double precision USING amount / POWER(10, %d)`, exp),
+			`ALTER TABLE payments DROP COLUMN IF EXISTS currency`,
+		},
+	}
+}
+
+func statusHistory() Migration {
+	return Migration{
+		ID:   "20251018100000",
+		Name: "payment status history and optimistic locking",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS payment_status_history (
+				id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+				payment_id uuid NOT NULL,
+				from_status text,
+				to_status text NOT NULL,
+				reason text,
+				actor text,
+				request_id text,
+				created_at timestamptz DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_payment_status_history_payment_id ON payment_status_history (payment_id)`,
+			`ALTER TABLE payments ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 0`,
+			`ALTER TABLE payments ADD COLUMN IF NOT EXISTS claimed_until timestamptz`,
+		},
+		Down: []string{
+			`ALTER TABLE payments DROP COLUMN IF EXISTS claimed_until`,
+			`ALTER TABLE payments DROP COLUMN IF EXISTS version`,
+			`DROP TABLE IF EXISTS payment_status_history`,
+		},
+	}
+}
+
+func idempotencyFingerprint() Migration {
+	return Migration{
+		ID:   "20251018110000",
+		Name: "idempotency fingerprint, expiry and stored response",
+		Up: []string{
+			`ALTER TABLE payments ADD COLUMN IF NOT EXISTS request_hash char(64)`,
+			`ALTER TABLE payments ADD COLUMN IF NOT EXISTS idempotency_expires_at timestamptz`,
+			`ALTER TABLE payments ADD COLUMN IF NOT EXISTS response text`,
+		},
+		Down: []string{
+			`ALTER TABLE payments DROP COLUMN IF EXISTS response`,
+			`ALTER TABLE payments DROP COLUMN IF EXISTS idempotency_expires_at`,
+			`ALTER TABLE payments DROP COLUMN IF EXISTS request_hash`,
+		},
+	}
+}
+
+func eventEnvelope() Migration {
+	return Migration{
+		ID:   "20251019090000",
+		Name: "outbox event envelope attributes",
+		Up: []string{
+			`ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS event_id uuid NOT NULL DEFAULT uuid_generate_v4()`,
+			`ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS schema_version text NOT NULL DEFAULT '1'`,
+			`ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS trace_id text`,
+		},
+		Down: []string{
+			`ALTER TABLE outbox_events DROP COLUMN IF EXISTS trace_id`,
+			`ALTER TABLE outbox_events DROP COLUMN IF EXISTS schema_version`,
+			`ALTER TABLE outbox_events DROP COLUMN IF EXISTS event_id`,
+		},
+	}
+}
+
+func webhooks() Migration {
+	return Migration{
+		ID:   "20251020090000",
+		Name: "merchant webhook endpoints, deliveries and delivery log",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS webhook_endpoints (
+				id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+				created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+				updated_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+				deleted_at timestamptz,
+				merchant_id text NOT NULL,
+				url text NOT NULL,
+				secret text NOT NULL,
+				event_types jsonb NOT NULL DEFAULT '[]'
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_webhook_endpoints_merchant_id ON webhook_endpoints (merchant_id)`,
+			`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+				id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+				endpoint_id uuid NOT NULL CONSTRAINT fk_webhook_deliveries_endpoint REFERENCES webhook_endpoints (id),
+				event_id uuid NOT NULL,
+				event_type text NOT NULL,
+				event_time timestamptz NOT NULL,
+				schema_version text NOT NULL,
+				trace_id text,
+				payload jsonb NOT NULL,
+				status text NOT NULL,
+				attempts bigint DEFAULT 0,
+				last_error text,
+				next_attempt_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+				delivered_at timestamptz,
+				created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+				updated_at timestamptz DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS uniq_webhook_delivery_event ON webhook_deliveries (endpoint_id, event_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at)`,
+			`CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+				id bigserial PRIMARY KEY,
+				delivery_id uuid NOT NULL CONSTRAINT fk_webhook_delivery_attempts_delivery REFERENCES webhook_deliveries (id),
+				attempt bigint NOT NULL,
+				status_code bigint,
+				error text,
+				duration_ms bigint NOT NULL DEFAULT 0,
+				created_at timestamptz DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts (delivery_id)`,
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS webhook_delivery_attempts`,
+			`DROP TABLE IF EXISTS webhook_deliveries`,
+			`DROP TABLE IF EXISTS webhook_endpoints`,
+		},
+	}
+}
+
+func outboxTraceParent() Migration {
+	return Migration{
+		ID:   "20251021090000",
+		Name: "outbox event trace context",
+		Up: []string{
+			`ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS trace_parent text`,
+		},
+		Down: []string{
+			`ALTER TABLE outbox_events DROP COLUMN IF EXISTS trace_parent`,
+		},
+	}
+}
+
+// authorizationExpiry records when a payment was authorized so lapsed authorizations can
+// be expired. Payments authorized before it are dated by their last update.
+func authorizationExpiry() Migration {
+	return Migration{
+		ID:   "20251105090000",
+		Name: "payment authorization time",
+		Up: []string{
+			`ALTER TABLE payments ADD COLUMN IF NOT EXISTS authorized_at timestamptz`,
+			`UPDATE payments SET authorized_at = updated_at WHERE status = 'AUTHORIZED' AND authorized_at IS NULL`,
+			`CREATE INDEX IF NOT EXISTS idx_payments_authorized_at ON payments (authorized_at) WHERE status = 'AUTHORIZED'`,
+		},
+		Down: []string{
+			`DROP INDEX IF EXISTS idx_payments_authorized_at`,
+			`ALTER TABLE payments DROP COLUMN IF EXISTS authorized_at`,
+		},
+	}
+}
+
+func refundClaim() Migration {
+	return Migration{
+		ID:   "20251106090000",
+		Name: "refund claim",
+		Up: []string{
+			`ALTER TABLE refunds ADD COLUMN IF NOT EXISTS claimed_until timestamptz`,
+		},
+		Down: []string{
+			`ALTER TABLE refunds DROP COLUMN IF EXISTS claimed_until`,
+		},
+	}
+}
@@ -0,0 +1,202 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+	"io"
+	"strings"
+)
+
+// advisoryLockKey serializes migration runs across every instance sharing the database
+const advisoryLockKey int64 = 0x7061796d656e74 // "payment"
+
+var gormigrateOptions = &gormigrate.Options{
+	TableName:      gormigrate.DefaultOptions.TableName,
+	IDColumnName:   gormigrate.DefaultOptions.IDColumnName,
+	IDColumnSize:   gormigrate.DefaultOptions.IDColumnSize,
+	UseTransaction: true,
+}
+
+// ErrNothingToRollback is returned by Down and Redo when no migration has been applied
+var ErrNothingToRollback = errors.New("no applied migration to roll back")
+
+// Runner applies and rolls back migrations. With DryRun the SQL is printed to Out
+// and the database is left untouched.
+type Runner struct {
+	db         *gorm.DB
+	migrations []Migration
+	DryRun     bool
+	Out        io.Writer
+}
+
+func NewRunner(db *gorm.DB, out io.Writer) *Runner {
+	return &Runner{db: db, migrations: All(), Out: out}
+}
+
+// Up applies every pending migration in order
+func (r *Runner) Up(ctx context.Context) error {
+	return r.locked(ctx, func(db *gorm.DB) error {
+		if r.DryRun {
+			pending, err := r.pending(db)
+			if err != nil {
+				return err
+			}
+			for _, m := range pending {
+				r.print("up", m, m.Up)
+			}
+			return nil
+		}
+		return gormigrate.New(db, gormigrateOptions, r.gormigrations()).Migrate()
+	})
+}
+
+// Down rolls back the last applied migration
+func (r *Runner) Down(ctx context.Context) error {
+	return r.locked(ctx, func(db *gorm.DB) error {
+		return r.down(db)
+	})
+}
+
+// Redo rolls back the last applied migration and applies it again
+func (r *Runner) Redo(ctx context.Context) error {
+	return r.locked(ctx, func(db *gorm.DB) error {
+		last, err := r.last(db)
+		if err != nil {
+			return err
+		}
+		if err := r.down(db); err != nil {
+			return err
+		}
+		if r.DryRun {
+			r.print("up", last, last.Up)
+			return nil
+		}
+		return gormigrate.New(db, gormigrateOptions, r.gormigrations()).MigrateTo(last.ID)
+	})
+}
+
+// Status prints every migration with whether it has been applied
+func (r *Runner) Status(ctx context.Context) error {
+	db := r.db.WithContext(ctx)
+	applied, err := r.applied(db)
+	if err != nil {
+		return err
+	}
+	for _, m := range r.migrations {
+		state := "pending"
+		if applied[m.ID] {
+			state = "applied"
+		}
+		_, _ = fmt.Fprintf(r.Out, "%-8s %s %s\n", state, m.ID, m.Name)
+	}
+	return nil
+}
+
+// Pending returns the migrations not applied yet, in order
+func (r *Runner) Pending(ctx context.Context) ([]Migration, error) {
+	return r.pending(r.db.WithContext(ctx))
+}
+
+func (r *Runner) down(db *gorm.DB) error {
+	last, err := r.last(db)
+	if err != nil {
+		return err
+	}
+	if r.DryRun {
+		r.print("down", last, last.Down)
+		return nil
+	}
+	return gormigrate.New(db, gormigrateOptions, r.gormigrations()).RollbackLast()
+}
+
+// locked runs fn on a single connection holding the migration advisory lock, so
+// concurrent runs wait for each other instead of applying the same migration twice
+func (r *Runner) locked(ctx context.Context, fn func(db *gorm.DB) error) error {
+	return r.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec(`SELECT pg_advisory_lock(?)`, advisoryLockKey).Error; err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		defer conn.Exec(`SELECT pg_advisory_unlock(?)`, advisoryLockKey)
+
+		return fn(conn)
+	})
+}
+
+// gormigrations registers the SQL migrations with gormigrate
+func (r *Runner) gormigrations() []*gormigrate.Migration {
+	out := make([]*gormigrate.Migration, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		out = append(out, &gormigrate.Migration{
+			ID: m.ID,
+			Migrate: func(tx *gorm.DB) error {
+				return exec(tx, m.Up)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return exec(tx, m.Down)
+			},
+		})
+	}
+	return out
+}
+
+// applied returns the IDs recorded in the migrations table, which may not exist yet
+func (r *Runner) applied(db *gorm.DB) (map[string]bool, error) {
+	applied := make(map[string]bool)
+	if !db.Migrator().HasTable(gormigrateOptions.TableName) {
+		return applied, nil
+	}
+	var ids []string
+	if err := db.Table(gormigrateOptions.TableName).Pluck(gormigrateOptions.IDColumnName, &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		applied[id] = true
+	}
+	return applied, nil
+}
+
+func (r *Runner) pending(db *gorm.DB) ([]Migration, error) {
+	applied, err := r.applied(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range r.migrations {
+		if !applied[m.ID] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+func (r *Runner) last(db *gorm.DB) (Migration, error) {
+	applied, err := r.applied(db)
+	if err != nil {
+		return Migration{}, err
+	}
+	for i := len(r.migrations) - 1; i >= 0; i-- {
+		if applied[r.migrations[i].ID] {
+			return r.migrations[i], nil
+		}
+	}
+	return Migration{}, ErrNothingToRollback
+}
+
+func (r *Runner) print(direction string, m Migration, statements []string) {
+	_, _ = fmt.Fprintf(r.Out, "-- %s %s %s\n", direction, m.ID, m.Name)
+	for _, sql := range statements {
+		_, _ = fmt.Fprintf(r.Out, "%s;\n", strings.TrimSpace(sql))
+	}
+}
+
+func exec(tx *gorm.DB, statements []string) error {
+	for _, sql := range statements {
+		if err := tx.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// StatusChange describes a requested payment status transition
+type StatusChange struct {
+	Status PaymentStatus
+	// Reason is recorded in the status history
+	Reason string
+	// Error is stored as last_error and counted as a failed attempt
+	Error string
+	// Version, when set, applies the change only if the payment is still at this version
+	Version int64
+	// Response, when set, is stored as the response replayed to idempotent retries
+	Response string
+	// GatewayRef, when set, is stored as the gateway reference of the payment
+	GatewayRef string
+}
+
+// HistoryReason returns the reason recorded in the status history
+func (c StatusChange) HistoryReason() string {
+	if c.Reason != "" {
+		return c.Reason
+	}
+	return c.Error
+}
+
+// PaymentStatusHistory records one payment status transition
+type PaymentStatusHistory struct {
+	ID         uuid.UUID     `gorm:"primary_key;type:uuid;default:uuid_generate_v4()" json:"id"`
+	PaymentID  uuid.UUID     `gorm:"type:uuid;index;not null" json:"payment_id"`
+	FromStatus PaymentStatus `gorm:"type:text" json:"from_status"`
+	ToStatus   PaymentStatus `gorm:"type:text;not null" json:"to_status"`
+	Reason     string        `gorm:"type:text" json:"reason"`
+	Actor      string        `gorm:"type:text" json:"actor"`
+	RequestID  string        `gorm:"type:text" json:"request_id"`
+	CreatedAt  time.Time     `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (PaymentStatusHistory) TableName() string {
+	return "payment_status_history"
+}
@@ -0,0 +1,12 @@
+package models
+
+// OrderCreatedEvent is published by the order service when an order is placed.
+// Amount is in the minor unit of Currency.
+type OrderCreatedEvent struct {
+	EventID    string `json:"event_id"`
+	OrderID    string `json:"order_id"`
+	CustomerID string `json:"customer_id"`
+	Amount     int64  `json:"amount"`
+	Currency   string `json:"currency"`
+	CardToken  string `json:"card_token"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+type OutboxStatus string
+
+const (
+	// OutboxPending describes an event waiting to be relayed to kafka
+	OutboxPending OutboxStatus = "PENDING"
+	// OutboxDelivered describes an event acknowledged by kafka
+	OutboxDelivered OutboxStatus = "DELIVERED"
+	// OutboxFailed describes an event that exhausted its delivery attempts
+	OutboxFailed OutboxStatus = "FAILED"
+)
+
+// OutboxEvent is written in the same transaction as the state change it describes
+// and relayed to kafka afterwards. ID is a sequence so events keep their write order.
+type OutboxEvent struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+	// EventID identifies the event for consumers, it is kept across delivery attempts
+	EventID       uuid.UUID `gorm:"type:uuid;not null;default:uuid_generate_v4()"`
+	AggregateKey  string    `gorm:"type:text;index;not null"`
+	EventType     string    `gorm:"type:text;not null"`
+	SchemaVersion string    `gorm:"type:text;not null;default:'1'"`
+	TraceID       string    `gorm:"type:text"`
+	// TraceParent is the W3C trace context of the request that wrote the event, the
+	// relay publishes the event in the same trace
+	TraceParent   string       `gorm:"type:text"`
+	Payload       string       `gorm:"type:jsonb;not null"`
+	Status        OutboxStatus `gorm:"type:text;index;not null"`
+	Attempts      int          `gorm:"default:0"`
+	LastError     string       `gorm:"type:text"`
+	NextAttemptAt time.Time    `gorm:"index;default:CURRENT_TIMESTAMP"`
+	DeliveredAt   *time.Time
+	CreatedAt     time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// EventSchemaVersion is the version of the event payloads, bump it on breaking changes
+const EventSchemaVersion = "1"
+
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// NewOutboxEvent marshals payload into a PENDING outbox event keyed by aggregateKey
+func NewOutboxEvent(aggregateKey, eventType string, payload interface{}) (*OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxEvent{
+		EventID:       uuid.New(),
+		AggregateKey:  aggregateKey,
+		EventType:     eventType,
+		SchemaVersion: EventSchemaVersion,
+		Payload:       string(data),
+		Status:        OutboxPending,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
+
+// PaymentEventType returns the event type describing a payment entering status, eg. payment.authorized
+func PaymentEventType(status PaymentStatus) string {
+	return "payment." + strings.ToLower(string(status))
+}
+
+// NewPaymentOutboxEvent builds the outbox event describing the current state of a payment
+func NewPaymentOutboxEvent(p *Payment) (*OutboxEvent, error) {
+	return NewOutboxEvent(p.ID.String(), PaymentEventType(p.Status), NewPaymentEvent(p))
+}
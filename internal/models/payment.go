@@ -1,8 +1,13 @@
 package models
 
 import (
+	"errors"
 	"github.com/google/uuid"
+	"payment/pkg/core/money"
 	"payment/pkg/http/utils"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type PaymentStatus string
@@ -14,33 +19,155 @@ const (
 	PaymentAuthorized PaymentStatus = "AUTHORIZED"
 	// PaymentDeclined describes a payment that has been declined
 	PaymentDeclined PaymentStatus = "DECLINED"
+	// PaymentCaptured describes a payment whose authorized amount has been fully captured
+	PaymentCaptured PaymentStatus = "CAPTURED"
+	// PaymentPartiallyCaptured describes a payment with only part of the authorized amount captured
+	PaymentPartiallyCaptured PaymentStatus = "PARTIALLY_CAPTURED"
+	// PaymentVoided describes an authorization that has been released without capture
+	PaymentVoided PaymentStatus = "VOIDED"
+	// PaymentExpired describes an authorization that lapsed before being captured
+	PaymentExpired PaymentStatus = "EXPIRED"
 )
 
+// ErrInvalidTransition is returned when a status change is not allowed by paymentTransitions
+var ErrInvalidTransition = errors.New("invalid payment status transition")
+
+// ErrConcurrentUpdate is returned when a payment changed since it was read, or is claimed by another caller
+var ErrConcurrentUpdate = errors.New("payment was modified concurrently")
+
+// paymentTransitions lists, for every status, the statuses a payment may move to.
+// PENDING -> PENDING is allowed so a failed gateway attempt can be recorded.
+var paymentTransitions = map[PaymentStatus][]PaymentStatus{
+	PaymentPending:           {PaymentPending, PaymentAuthorized, PaymentDeclined},
+	PaymentAuthorized:        {PaymentCaptured, PaymentPartiallyCaptured, PaymentVoided, PaymentExpired},
+	PaymentPartiallyCaptured: {PaymentPartiallyCaptured, PaymentCaptured},
+}
+
+// CanTransition reports whether a payment may move from one status to another
+func CanTransition(from, to PaymentStatus) bool {
+	for _, next := range paymentTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceStatuses returns every status from which a payment may move to the given status
+func SourceStatuses(to PaymentStatus) []PaymentStatus {
+	var from []PaymentStatus
+	for status, nexts := range paymentTransitions {
+		for _, next := range nexts {
+			if next == to {
+				from = append(from, status)
+				break
+			}
+		}
+	}
+	return from
+}
+
+// IsFinal reports whether no further transition is possible from the status
+func (s PaymentStatus) IsFinal() bool {
+	return len(paymentTransitions[s]) == 0
+}
+
+// Payment is a payment of an order. Amounts are stored in the minor unit of Currency,
+// eg. cents for USD.
 type Payment struct {
 	BaseModel
 	OrderID        string        `gorm:"index"`
 	IdempotencyKey string        `gorm:"uniqueIndex:uniq_idem_key"`
-	Amount         float64       `gorm:"not null"`
+	Amount         int64         `gorm:"type:bigint;not null"`
+	Currency       string        `gorm:"type:char(3);not null;default:'USD'"`
 	Status         PaymentStatus `gorm:"type:text;index;not null"`
 	Attempts       int           `gorm:"default:0"`
 	LastError      string        `gorm:"type:text"`
+	CardToken      string        `gorm:"type:text"`
+	GatewayRef     string        `gorm:"type:text"`
+	CapturedAmount int64         `gorm:"type:bigint;not null;default:0"`
+	RefundedAmount int64         `gorm:"type:bigint;not null;default:0"`
+	// Version is bumped on every state change and used for compare-and-swap updates
+	Version int64 `gorm:"not null;default:0"`
+	// ClaimedUntil is set while a caller is driving the gateway for a PENDING payment
+	ClaimedUntil *time.Time
+	// RequestHash is the SHA-256 fingerprint of the request that created the payment
+	RequestHash string `gorm:"type:char(64)"`
+	// IdempotencyExpiresAt is when IdempotencyKey stops being honoured, nil never expires
+	IdempotencyExpiresAt *time.Time
+	// Response is the JSON of the final Pay response, replayed to matching retries
+	Response string `gorm:"type:text"`
+	// AuthorizedAt is when the payment was authorized, the authorization expires after AUTHORIZATION_TTL
+	AuthorizedAt *time.Time
+}
+
+// Total returns the authorized amount
+func (p *Payment) Total() money.Money {
+	return money.Money{Amount: p.Amount, Currency: p.Currency}
+}
+
+// RemainingAmount returns the authorized amount that has not been captured yet
+func (p *Payment) RemainingAmount() int64 {
+	return p.Amount - p.CapturedAmount
+}
+
+// RefundableAmount returns the captured amount that has not been refunded or reserved for a refund yet
+func (p *Payment) RefundableAmount() int64 {
+	return p.CapturedAmount - p.RefundedAmount
+}
+
+// MatchesRequest reports whether req is a retry of the request that created the payment.
+// Payments created before fingerprints were stored are compared field by field.
+func (p *Payment) MatchesRequest(req *CreatePaymentRequest) bool {
+	if p.RequestHash != "" {
+		return p.RequestHash == req.Fingerprint()
+	}
+	return p.OrderID == req.OrderID && p.Amount == req.Amount && p.Currency == req.Currency
 }
 
 func (Payment) TableName() string {
 	return "payments"
 }
 
+func (Payment) GetSortableFields() []string {
+	return []string{"created_at", "updated_at", "amount", "status", "order_id"}
+}
+
+// PaymentFilter narrows ListPayments, every empty field is ignored
+type PaymentFilter struct {
+	OrderID    string     `json:"order_id" form:"order_id"`
+	Status     string     `json:"status" form:"status"`
+	CreateFrom *time.Time `json:"create_from" form:"create_from"`
+	CreateTo   *time.Time `json:"create_to" form:"create_to"`
+}
+
 type CreatePaymentRequest struct {
-	OrderID        string  `json:"order_id" binding:"required"`
-	IdempotencyKey string  `json:"idempotency_key" binding:"required"`
-	Amount         float64 `json:"amount" binding:"required"`
+	OrderID        string `json:"order_id" binding:"required"`
+	IdempotencyKey string `json:"idempotency_key" binding:"required"`
+	Amount         int64  `json:"amount" binding:"required"`
+	Currency       string `json:"currency" binding:"required"`
+	CardToken      string `json:"card_token"`
+	// ExpiresAt bounds how long IdempotencyKey is honoured, nil never expires
+	ExpiresAt *time.Time `json:"-"`
+}
+
+// Fingerprint returns the SHA-256 hash of the normalized request, two requests with
+// the same fingerprint are retries of each other
+func (r *CreatePaymentRequest) Fingerprint() string {
+	return utils.HashWithSHA256(strings.Join([]string{
+		strings.TrimSpace(r.OrderID),
+		strconv.FormatInt(r.Amount, 10),
+		strings.ToUpper(strings.TrimSpace(r.Currency)),
+		strings.TrimSpace(r.CardToken),
+	}, "|"))
 }
 
 type CreatePaymentResponseData struct {
 	PaymentID      uuid.UUID `json:"payment_id"`
 	OrderID        string    `json:"order_id"`
 	IdempotencyKey string    `json:"idempotency_key"`
-	Amount         float64   `json:"amount"`
+	Amount         int64     `json:"amount"`
+	Currency       string    `json:"currency"`
 	Status         string    `json:"status"`
 }
 
@@ -57,15 +184,67 @@ func NewCreatePaymentResponse(p *Payment, md *utils.MetaData) *CreatePaymentResp
 			OrderID:        p.OrderID,
 			IdempotencyKey: p.IdempotencyKey,
 			Amount:         p.Amount,
+			Currency:       p.Currency,
 			Status:         string(p.Status),
 		},
 	}
 }
 
+type PaymentResponseData struct {
+	PaymentID      uuid.UUID `json:"payment_id"`
+	OrderID        string    `json:"order_id"`
+	IdempotencyKey string    `json:"idempotency_key"`
+	Amount         int64     `json:"amount"`
+	CapturedAmount int64     `json:"captured_amount"`
+	RefundedAmount int64     `json:"refunded_amount"`
+	Currency       string    `json:"currency"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	LastError      string    `json:"last_error"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type PaymentResponse struct {
+	Meta *utils.MetaData     `json:"meta"`
+	Data PaymentResponseData `json:"data"`
+}
+
+func NewPaymentResponseData(p *Payment) PaymentResponseData {
+	return PaymentResponseData{
+		PaymentID:      p.ID,
+		OrderID:        p.OrderID,
+		IdempotencyKey: p.IdempotencyKey,
+		Amount:         p.Amount,
+		CapturedAmount: p.CapturedAmount,
+		RefundedAmount: p.RefundedAmount,
+		Currency:       p.Currency,
+		Status:         string(p.Status),
+		Attempts:       p.Attempts,
+		LastError:      p.LastError,
+		CreatedAt:      p.CreatedAt,
+		UpdatedAt:      p.UpdatedAt,
+	}
+}
+
 type PaymentEvent struct {
-	PaymentID      string  `json:"payment_id"`
-	OrderID        string  `json:"order_id"`
-	IdempotencyKey string  `json:"idempotency_key"`
-	Amount         float64 `json:"amount"`
-	Status         string  `json:"status"`
+	PaymentID      string `json:"payment_id"`
+	OrderID        string `json:"order_id"`
+	IdempotencyKey string `json:"idempotency_key"`
+	Amount         int64  `json:"amount"`
+	CapturedAmount int64  `json:"captured_amount"`
+	Currency       string `json:"currency"`
+	Status         string `json:"status"`
+}
+
+func NewPaymentEvent(p *Payment) PaymentEvent {
+	return PaymentEvent{
+		PaymentID:      p.ID.String(),
+		OrderID:        p.OrderID,
+		IdempotencyKey: p.IdempotencyKey,
+		Amount:         p.Amount,
+		CapturedAmount: p.CapturedAmount,
+		Currency:       p.Currency,
+		Status:         string(p.Status),
+	}
 }
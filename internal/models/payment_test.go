@@ -0,0 +1,67 @@
+package models
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from PaymentStatus
+		to   PaymentStatus
+		want bool
+	}{
+		{from: PaymentPending, to: PaymentPending, want: true},
+		{from: PaymentPending, to: PaymentAuthorized, want: true},
+		{from: PaymentPending, to: PaymentDeclined, want: true},
+		{from: PaymentPending, to: PaymentCaptured},
+		{from: PaymentAuthorized, to: PaymentCaptured, want: true},
+		{from: PaymentAuthorized, to: PaymentPartiallyCaptured, want: true},
+		{from: PaymentAuthorized, to: PaymentVoided, want: true},
+		{from: PaymentAuthorized, to: PaymentExpired, want: true},
+		{from: PaymentAuthorized, to: PaymentPending},
+		{from: PaymentPartiallyCaptured, to: PaymentPartiallyCaptured, want: true},
+		{from: PaymentPartiallyCaptured, to: PaymentCaptured, want: true},
+		{from: PaymentPartiallyCaptured, to: PaymentVoided},
+		{from: PaymentCaptured, to: PaymentVoided},
+		{from: PaymentDeclined, to: PaymentAuthorized},
+		{from: PaymentVoided, to: PaymentCaptured},
+		{from: PaymentExpired, to: PaymentAuthorized},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreatePaymentRequestFingerprint(t *testing.T) {
+	base := CreatePaymentRequest{OrderID: "o-1", IdempotencyKey: "k-1", Amount: 1234, Currency: "USD", CardToken: "tok_1"}
+
+	tests := []struct {
+		name string
+		req  CreatePaymentRequest
+		same bool
+	}{
+		{name: "identical", req: base, same: true},
+		{name: "other idempotency key", req: with(base, func(r *CreatePaymentRequest) { r.IdempotencyKey = "k-2" }), same: true},
+		{name: "lower case currency", req: with(base, func(r *CreatePaymentRequest) { r.Currency = "usd" }), same: true},
+		{name: "padded fields", req: with(base, func(r *CreatePaymentRequest) { r.OrderID = " o-1 "; r.CardToken = "tok_1 " }), same: true},
+		{name: "other order", req: with(base, func(r *CreatePaymentRequest) { r.OrderID = "o-2" })},
+		{name: "other amount", req: with(base, func(r *CreatePaymentRequest) { r.Amount = 1235 })},
+		{name: "other currency", req: with(base, func(r *CreatePaymentRequest) { r.Currency = "EUR" })},
+		{name: "other card", req: with(base, func(r *CreatePaymentRequest) { r.CardToken = "tok_2" })},
+	}
+	want := base.Fingerprint()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.req.Fingerprint(); (got == want) != tt.same {
+				t.Errorf("Fingerprint() = %s, base %s, want equal %v", got, want, tt.same)
+			}
+		})
+	}
+}
+
+func with(req CreatePaymentRequest, modify func(r *CreatePaymentRequest)) CreatePaymentRequest {
+	modify(&req)
+	return req
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"payment/pkg/http/utils"
+	"time"
+)
+
+type RefundStatus string
+
+const (
+	// RefundPending describes a refund that is reserved but not yet confirmed by the gateway
+	RefundPending RefundStatus = "PENDING"
+	// RefundSucceeded describes a refund the gateway has accepted
+	RefundSucceeded RefundStatus = "SUCCEEDED"
+	// RefundFailed describes a refund the gateway has rejected
+	RefundFailed RefundStatus = "FAILED"
+)
+
+// EventTypePaymentRefunded is the type of the event emitted when a refund succeeds
+const EventTypePaymentRefunded = "payment.refunded"
+
+type Refund struct {
+	BaseModel
+	PaymentID      uuid.UUID    `gorm:"type:uuid;index;not null"`
+	Payment        *Payment     `gorm:"foreignKey:PaymentID" json:"-"`
+	IdempotencyKey string       `gorm:"uniqueIndex:uniq_refund_idem_key"`
+	Amount         int64        `gorm:"type:bigint;not null"`
+	Currency       string       `gorm:"type:char(3);not null;default:'USD'"`
+	Reason         string       `gorm:"type:text"`
+	Status         RefundStatus `gorm:"type:text;index;not null"`
+	GatewayRef     string       `gorm:"type:text"`
+	LastError      string       `gorm:"type:text"`
+	// ClaimedUntil is set while a caller is driving the gateway for a PENDING refund
+	ClaimedUntil *time.Time
+}
+
+func (Refund) TableName() string {
+	return "refunds"
+}
+
+type CreateRefundRequest struct {
+	IdempotencyKey string `json:"idempotency_key" binding:"required"`
+	Amount         int64  `json:"amount" binding:"required"`
+	Currency       string `json:"currency" binding:"required"`
+	Reason         string `json:"reason"`
+}
+
+type CreateRefundResponseData struct {
+	RefundID       string `json:"refund_id"`
+	PaymentID      string `json:"payment_id"`
+	Amount         int64  `json:"amount"`
+	RefundedAmount int64  `json:"refunded_amount"`
+	Currency       string `json:"currency"`
+	Status         string `json:"status"`
+}
+
+type CreateRefundResponse struct {
+	Meta *utils.MetaData          `json:"meta"`
+	Data CreateRefundResponseData `json:"data"`
+}
+
+type RefundEvent struct {
+	Type           string `json:"type"`
+	RefundID       string `json:"refund_id"`
+	PaymentID      string `json:"payment_id"`
+	OrderID        string `json:"order_id"`
+	IdempotencyKey string `json:"idempotency_key"`
+	Amount         int64  `json:"amount"`
+	RefundedAmount int64  `json:"refunded_amount"`
+	Currency       string `json:"currency"`
+	Status         string `json:"status"`
+}
+
+func NewRefundEvent(r *Refund, p *Payment) RefundEvent {
+	return RefundEvent{
+		Type:           EventTypePaymentRefunded,
+		RefundID:       r.ID.String(),
+		PaymentID:      p.ID.String(),
+		OrderID:        p.OrderID,
+		IdempotencyKey: r.IdempotencyKey,
+		Amount:         r.Amount,
+		RefundedAmount: p.RefundedAmount,
+		Currency:       r.Currency,
+		Status:         string(r.Status),
+	}
+}
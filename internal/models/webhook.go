@@ -0,0 +1,167 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"payment/pkg/http/utils"
+	"time"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending describes a delivery waiting for its next attempt
+	WebhookDeliveryPending WebhookDeliveryStatus = "PENDING"
+	// WebhookDeliveryDelivered describes a delivery the endpoint answered with a 2xx status
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "DELIVERED"
+	// WebhookDeliveryFailed describes a delivery that exhausted its attempts, it can be replayed
+	WebhookDeliveryFailed WebhookDeliveryStatus = "FAILED"
+)
+
+// EventFilter lists the event types an endpoint subscribes to, empty means every type
+type EventFilter []string
+
+func (f EventFilter) Value() (driver.Value, error) {
+	if f == nil {
+		f = EventFilter{}
+	}
+	data, err := json.Marshal([]string(f))
+	return string(data), err
+}
+
+func (f *EventFilter) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*f = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, (*[]string)(f))
+	case string:
+		return json.Unmarshal([]byte(v), (*[]string)(f))
+	}
+	return fmt.Errorf("unsupported event filter value %T", src)
+}
+
+// WebhookEndpoint is a merchant URL notified of payment events. Secret signs every
+// delivery and is only returned when the endpoint is registered.
+type WebhookEndpoint struct {
+	BaseModel
+	MerchantID string      `gorm:"type:text;index;not null"`
+	URL        string      `gorm:"column:url;type:text;not null"`
+	Secret     string      `gorm:"type:text;not null"`
+	EventTypes EventFilter `gorm:"type:jsonb;not null;default:'[]'"`
+}
+
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// WebhookDelivery is one event to send to one endpoint. It is written with the outbox
+// event it carries, so an endpoint is notified of every change that commits.
+type WebhookDelivery struct {
+	ID            uuid.UUID             `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()"`
+	EndpointID    uuid.UUID             `gorm:"type:uuid;index;not null"`
+	Endpoint      *WebhookEndpoint      `gorm:"foreignKey:EndpointID" json:"-"`
+	EventID       uuid.UUID             `gorm:"type:uuid;not null"`
+	EventType     string                `gorm:"type:text;not null"`
+	EventTime     time.Time             `gorm:"not null"`
+	SchemaVersion string                `gorm:"type:text;not null"`
+	TraceID       string                `gorm:"type:text"`
+	Payload       string                `gorm:"type:jsonb;not null"`
+	Status        WebhookDeliveryStatus `gorm:"type:text;index;not null"`
+	Attempts      int                   `gorm:"default:0"`
+	LastError     string                `gorm:"type:text"`
+	NextAttemptAt time.Time             `gorm:"index;default:CURRENT_TIMESTAMP"`
+	DeliveredAt   *time.Time
+	CreatedAt     time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	UpdatedAt     time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// WebhookDeliveryAttempt is the delivery log, one row per request sent to the endpoint
+type WebhookDeliveryAttempt struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement"`
+	DeliveryID uuid.UUID `gorm:"type:uuid;index;not null"`
+	Attempt    int       `gorm:"not null"`
+	StatusCode int
+	Error      string    `gorm:"type:text"`
+	DurationMs int64     `gorm:"not null;default:0"`
+	CreatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+func (WebhookDeliveryAttempt) TableName() string {
+	return "webhook_delivery_attempts"
+}
+
+type CreateWebhookEndpointRequest struct {
+	MerchantID string   `json:"merchant_id" binding:"required"`
+	URL        string   `json:"url" binding:"required"`
+	Secret     string   `json:"secret"` // generated when empty
+	EventTypes []string `json:"event_types"`
+}
+
+type WebhookEndpointResponseData struct {
+	ID         uuid.UUID `json:"id"`
+	MerchantID string    `json:"merchant_id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func NewWebhookEndpointResponseData(e *WebhookEndpoint) WebhookEndpointResponseData {
+	eventTypes := []string(e.EventTypes)
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	return WebhookEndpointResponseData{
+		ID:         e.ID,
+		MerchantID: e.MerchantID,
+		URL:        e.URL,
+		EventTypes: eventTypes,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+type WebhookEndpointResponse struct {
+	Meta *utils.MetaData             `json:"meta"`
+	Data WebhookEndpointResponseData `json:"data"`
+}
+
+type WebhookDeliveryResponseData struct {
+	ID            uuid.UUID             `json:"id"`
+	EndpointID    uuid.UUID             `json:"endpoint_id"`
+	EventID       uuid.UUID             `json:"event_id"`
+	EventType     string                `json:"event_type"`
+	Status        WebhookDeliveryStatus `json:"status"`
+	Attempts      int                   `json:"attempts"`
+	LastError     string                `json:"last_error,omitempty"`
+	NextAttemptAt time.Time             `json:"next_attempt_at"`
+	DeliveredAt   *time.Time            `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time             `json:"created_at"`
+}
+
+func NewWebhookDeliveryResponseData(d *WebhookDelivery) WebhookDeliveryResponseData {
+	return WebhookDeliveryResponseData{
+		ID:            d.ID,
+		EndpointID:    d.EndpointID,
+		EventID:       d.EventID,
+		EventType:     d.EventType,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		LastError:     d.LastError,
+		NextAttemptAt: d.NextAttemptAt,
+		DeliveredAt:   d.DeliveredAt,
+		CreatedAt:     d.CreatedAt,
+	}
+}
+
+type WebhookDeliveryResponse struct {
+	Meta *utils.MetaData             `json:"meta"`
+	Data WebhookDeliveryResponseData `json:"data"`
+}
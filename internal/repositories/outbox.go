@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	model "payment/internal/models"
+	pgGorm "payment/internal/repositories/pg-gorm"
+	"payment/pkg/core/tracing"
+	"payment/pkg/http/utils"
+	"time"
+)
+
+type OutboxRepository struct {
+	db pgGorm.PGInterface
+}
+
+func NewOutboxRepository(newPgRepo pgGorm.PGInterface) *OutboxRepository {
+	return &OutboxRepository{db: newPgRepo}
+}
+
+type OutboxRepoInterface interface {
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]model.OutboxEvent, error)
+	RecordDelivery(ctx context.Context, evt *model.OutboxEvent) error
+}
+
+// ClaimDue claims up to limit due events in write order and pushes their next attempt
+// past lease, so other relays skip them while they are published without holding a
+// transaction open. Only the oldest pending event of every aggregate key is claimed, so
+// events of the same payment are never relayed out of order, and rows are locked with
+// SKIP LOCKED so several relays can run side by side.
+func (r *OutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]model.OutboxEvent, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	var events []model.OutboxEvent
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.OutboxPending, now).
+			Where(`NOT EXISTS (SELECT 1 FROM outbox_events prev
+				WHERE prev.aggregate_key = outbox_events.aggregate_key
+				AND prev.status = ? AND prev.id < outbox_events.id)`, model.OutboxPending).
+			Order("id").
+			Limit(limit).
+			Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		ids := make([]uint64, 0, len(events))
+		for i := range events {
+			ids = append(ids, events[i].ID)
+			events[i].NextAttemptAt = now.Add(lease)
+		}
+		return tx.Model(&model.OutboxEvent{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// RecordDelivery saves the delivery fields of an event claimed by ClaimDue
+func (r *OutboxRepository) RecordDelivery(ctx context.Context, evt *model.OutboxEvent) error {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	return tx.Model(evt).Select("status", "attempts", "last_error", "next_attempt_at", "delivered_at").
+		Updates(evt).Error
+}
+
+// enqueueOutbox writes events with the transaction of the state change they describe,
+// along with their webhook deliveries. Events are traced with the request id and the
+// trace context of ctx.
+func enqueueOutbox(ctx context.Context, tx *gorm.DB, events ...*model.OutboxEvent) error {
+	for _, evt := range events {
+		if evt == nil {
+			continue
+		}
+		if evt.TraceID == "" {
+			evt.TraceID = utils.RequestIDFromContext(ctx)
+		}
+		if evt.TraceParent == "" {
+			evt.TraceParent = tracing.TraceParent(ctx)
+		}
+		if err := tx.Create(evt).Error; err != nil {
+			return err
+		}
+		if err := enqueueWebhookDeliveries(tx, evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,11 +3,23 @@ package repositories
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	model "payment/internal/models"
 	pgGorm "payment/internal/repositories/pg-gorm"
+	"payment/pkg/core/backoff"
+	"payment/pkg/http/paging"
 	"payment/pkg/http/utils"
+	"time"
+)
+
+var (
+	// ErrCaptureAmount is returned when a capture is not positive or exceeds the uncaptured amount
+	ErrCaptureAmount = errors.New("capture amount exceeds remaining authorized amount")
+	// ErrIdempotencyMismatch is returned when an idempotency key is reused with different payment data
+	ErrIdempotencyMismatch = errors.New("payment data mismatch for existing idempotency key")
 )
 
 type PaymentRepository struct {
@@ -20,9 +32,32 @@ func NewPaymentRepository(newPgRepo pgGorm.PGInterface) *PaymentRepository {
 
 type PaymentRepoInterface interface {
 	CreateOrGetPayment(ctx context.Context, req *model.CreatePaymentRequest) (*model.Payment, bool, error)
-	UpdateStatus(ctx context.Context, paymentID string, status model.PaymentStatus, lastErr string) error
+	UpdateStatus(ctx context.Context, paymentID string, change model.StatusChange, events ...*model.OutboxEvent) error
+	ClaimPayment(ctx context.Context, paymentID string, version int64, lease time.Duration) (*model.Payment, error)
+	ReleaseClaim(ctx context.Context, paymentID string, version int64) error
+	GetPayment(ctx context.Context, paymentID string) (*model.Payment, error)
+	GetPaymentByIdempotencyKey(ctx context.Context, idempotencyKey string) (*model.Payment, error)
+	ListPayments(ctx context.Context, filter *model.PaymentFilter, pager *paging.Pager) ([]model.Payment, error)
+	GetPaymentHistory(ctx context.Context, paymentID string) ([]model.PaymentStatusHistory, error)
+	RecordCapture(ctx context.Context, paymentID string, version int64, amount int64) (*model.Payment, error)
+	ListStuckPending(ctx context.Context, query StuckPaymentQuery) ([]model.Payment, error)
+	ListExpiredAuthorizations(ctx context.Context, authorizedBefore time.Time, limit int) ([]model.Payment, error)
+}
+
+// StuckPaymentQuery selects PENDING payments due for reconciliation. A payment is due once
+// it has not changed for StuckAfter and, after failed attempts, once BaseBackoff doubled
+// for every attempt after the first, capped at MaxBackoff, has elapsed.
+type StuckPaymentQuery struct {
+	StuckAfter  time.Duration
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Limit       int
 }
 
+// CreateOrGetPayment creates a PENDING payment for req or returns the payment created
+// earlier with the same idempotency key, in which case created is false. Reusing a key
+// with a request that has a different fingerprint returns ErrIdempotencyMismatch. A key
+// past its expiry is retired, suffixing it with the payment id, so req creates a new payment.
 func (r *PaymentRepository) CreateOrGetPayment(
 	ctx context.Context,
 	req *model.CreatePaymentRequest) (
@@ -34,56 +69,379 @@ func (r *PaymentRepository) CreateOrGetPayment(
 	}
 
 	p := &model.Payment{
-		OrderID:        req.OrderID,
-		IdempotencyKey: req.IdempotencyKey,
-		Amount:         req.Amount,
-		Status:         model.PaymentPending,
+		OrderID:              req.OrderID,
+		IdempotencyKey:       req.IdempotencyKey,
+		Amount:               req.Amount,
+		Currency:             req.Currency,
+		CardToken:            req.CardToken,
+		Status:               model.PaymentPending,
+		RequestHash:          req.Fingerprint(),
+		IdempotencyExpiresAt: req.ExpiresAt,
 	}
 
-	// Try insert; do nothing on conflict (idempotency key)
-	res := tx.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "idempotency_key"}},
-		DoNothing: true,
-	}).Create(p)
+	created := false
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Payment{}).
+			Where("idempotency_key = ? AND idempotency_expires_at < ?", req.IdempotencyKey, time.Now()).
+			Update("idempotency_key", gorm.Expr("idempotency_key || ':' || id::text")).Error; err != nil {
+			return err
+		}
 
-	if res.Error != nil {
-		return nil, false, res.Error
-	}
+		// Try insert; do nothing on conflict (idempotency key)
+		res := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "idempotency_key"}},
+			DoNothing: true,
+		}).Create(p)
+		if res.Error != nil {
+			return res.Error
+		}
 
-	created := res.RowsAffected == 1
-	if !created {
-		// Fetch existing
+		created = res.RowsAffected == 1
+		if created {
+			return recordHistory(ctx, tx, p.ID, "", p.Status, "created")
+		}
+
+		// Fetch existing and validate consistency
 		var existing model.Payment
 		if err := tx.Where("idempotency_key = ?", req.IdempotencyKey).First(&existing).Error; err != nil {
-			return nil, false, err
+			return err
 		}
-		// Validate consistency
-		if existing.OrderID != req.OrderID || existing.Amount != req.Amount {
-			err := errors.New("payment data mismatch for existing idempotency key")
-			return nil, false, err
+		if !existing.MatchesRequest(req) {
+			return ErrIdempotencyMismatch
 		}
-		return &existing, false, nil
+		p = &existing
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
 	}
-
-	return p, true, nil
+	return p, created, nil
 }
 
-func (r *PaymentRepository) UpdateStatus(ctx context.Context, paymentID string, status model.PaymentStatus, lastErr string) error {
+// UpdateStatus moves a payment to change.Status. The row is locked and the change is
+// applied only when the current status allows it according to the model transition
+// table, otherwise model.ErrInvalidTransition is returned. When change.Version is set
+// and the payment moved past it, model.ErrConcurrentUpdate is returned. The update
+// bumps the version, releases any claim, is recorded in the status history and
+// writes events to the outbox in the same transaction.
+func (r *PaymentRepository) UpdateStatus(
+	ctx context.Context,
+	paymentID string,
+	change model.StatusChange,
+	events ...*model.OutboxEvent) error {
+
 	tx, cancel := r.db.DBWithTimeout(ctx)
 	defer cancel()
 
 	update := map[string]interface{}{
-		"status": status,
+		"status":        change.Status,
+		"version":       gorm.Expr("version + 1"),
+		"claimed_until": nil,
+	}
+	if change.Response != "" {
+		update["response"] = change.Response
+	}
+	if change.GatewayRef != "" {
+		update["gateway_ref"] = change.GatewayRef
 	}
-	if lastErr != "" {
-		update["last_error"] = lastErr
+	if change.Status == model.PaymentAuthorized {
+		update["authorized_at"] = time.Now()
+	}
+	if change.Error != "" {
+		update["last_error"] = change.Error
 		update["attempts"] = gorm.Expr("attempts + 1")
 	}
 
-	return tx.Model(&model.Payment{}).Where("id = ?", paymentID).Updates(update).Error
+	return tx.Transaction(func(tx *gorm.DB) error {
+		var current model.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "status", "version").Where("id = ?", paymentID).First(&current).Error; err != nil {
+			return err
+		}
+		if change.Version != 0 && current.Version != change.Version {
+			return model.ErrConcurrentUpdate
+		}
+		if !model.CanTransition(current.Status, change.Status) {
+			return model.ErrInvalidTransition
+		}
+
+		res := tx.Model(&model.Payment{}).
+			Where("id = ? AND version = ?", paymentID, current.Version).
+			Updates(update)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return model.ErrConcurrentUpdate
+		}
+		if err := recordHistory(ctx, tx, current.ID, current.Status, change.Status, change.HistoryReason()); err != nil {
+			return err
+		}
+		return enqueueOutbox(ctx, tx, events...)
+	})
+}
+
+// ClaimPayment takes exclusive ownership of a payment for lease so that a single caller
+// drives the gateway, eg. to authorize a PENDING payment or capture an AUTHORIZED one.
+// The claim is a compare-and-swap on version, so the payment is still in the status the
+// caller read, and only succeeds when no other caller holds an unexpired claim, otherwise
+// model.ErrConcurrentUpdate is returned. The claim is released by the next UpdateStatus,
+// RecordCapture or ReleaseClaim.
+func (r *PaymentRepository) ClaimPayment(
+	ctx context.Context,
+	paymentID string,
+	version int64,
+	lease time.Duration) (*model.Payment, error) {
+
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	until := now.Add(lease)
+	res := tx.Model(&model.Payment{}).
+		Where("id = ? AND version = ?", paymentID, version).
+		Where("claimed_until IS NULL OR claimed_until < ?", now).
+		Updates(map[string]interface{}{
+			"version":       gorm.Expr("version + 1"),
+			"claimed_until": until,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, model.ErrConcurrentUpdate
+	}
+
+	var p model.Payment
+	if err := tx.Where("id = ?", paymentID).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ReleaseClaim gives up a claim taken at version without changing the payment, eg. after
+// the gateway declined a capture. A claim that moved on in the meantime is left alone.
+func (r *PaymentRepository) ReleaseClaim(ctx context.Context, paymentID string, version int64) error {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	return tx.Model(&model.Payment{}).
+		Where("id = ? AND version = ?", paymentID, version).
+		Update("claimed_until", nil).Error
+}
+
+func (r *PaymentRepository) GetPayment(ctx context.Context, paymentID string) (*model.Payment, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	var p model.Payment
+	if err := tx.Where("id = ?", paymentID).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *PaymentRepository) GetPaymentByIdempotencyKey(ctx context.Context, idempotencyKey string) (*model.Payment, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	var p model.Payment
+	if err := tx.Where("idempotency_key = ?", idempotencyKey).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPayments returns one page of payments matching filter. pager.TotalRows is
+// filled with the number of matching rows; newest payments come first by default.
+func (r *PaymentRepository) ListPayments(ctx context.Context, filter *model.PaymentFilter, pager *paging.Pager) ([]model.Payment, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	query := tx.Model(&model.Payment{})
+	if filter != nil {
+		if filter.OrderID != "" {
+			query = query.Where("order_id = ?", filter.OrderID)
+		}
+		if filter.Status != "" {
+			query = query.Where("status = ?", filter.Status)
+		}
+		if filter.CreateFrom != nil {
+			query = query.Where("created_at >= ?", *filter.CreateFrom)
+		}
+		if filter.CreateTo != nil {
+			query = query.Where("created_at < ?", *filter.CreateTo)
+		}
+	}
+
+	if pager.Sort == "" {
+		pager.Sort = "-created_at"
+	}
+
+	var payments []model.Payment
+	if err := pager.DoQuery(&payments, query.Session(&gorm.Session{})).Error; err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// RecordCapture adds amount to the captured total of an authorized payment claimed at
+// version and moves it to CAPTURED or PARTIALLY_CAPTURED, releasing the claim. The row is
+// locked for the duration of the transaction, a payment that moved past version returns
+// model.ErrConcurrentUpdate. The capture event is written to the outbox in the same transaction.
+func (r *PaymentRepository) RecordCapture(ctx context.Context, paymentID string, version int64, amount int64) (*model.Payment, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	var p model.Payment
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", paymentID).First(&p).Error; err != nil {
+			return err
+		}
+		if p.Version != version {
+			return model.ErrConcurrentUpdate
+		}
+		if amount <= 0 || amount > p.RemainingAmount() {
+			return ErrCaptureAmount
+		}
+
+		next := model.PaymentPartiallyCaptured
+		if p.CapturedAmount+amount >= p.Amount {
+			next = model.PaymentCaptured
+		}
+		if !model.CanTransition(p.Status, next) {
+			return model.ErrInvalidTransition
+		}
+
+		from := p.Status
+		p.CapturedAmount += amount
+		p.Status = next
+		p.Version++
+		p.ClaimedUntil = nil
+		if err := tx.Model(&p).Updates(map[string]interface{}{
+			"captured_amount": p.CapturedAmount,
+			"status":          p.Status,
+			"version":         p.Version,
+			"claimed_until":   nil,
+		}).Error; err != nil {
+			return err
+		}
+		if err := recordHistory(ctx, tx, p.ID, from, p.Status, fmt.Sprintf("captured %d %s", amount, p.Currency)); err != nil {
+			return err
+		}
+
+		evt, err := model.NewPaymentOutboxEvent(&p)
+		if err != nil {
+			return err
+		}
+		return enqueueOutbox(ctx, tx, evt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPaymentHistory returns the status transitions of a payment, oldest first
+func (r *PaymentRepository) GetPaymentHistory(ctx context.Context, paymentID string) ([]model.PaymentStatusHistory, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	if err := tx.Model(&model.Payment{}).Where("id = ?", paymentID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var history []model.PaymentStatusHistory
+	if err := tx.Where("payment_id = ?", paymentID).Order("created_at, id").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// ListStuckPending returns unclaimed PENDING payments due for reconciliation, oldest first
+func (r *PaymentRepository) ListStuckPending(ctx context.Context, query StuckPaymentQuery) ([]model.Payment, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+
+	// One cutoff per attempt count until the backoff reaches its cap, every payment with
+	// more attempts shares the last cutoff
+	due := tx.Where("attempts = 0")
+	for attempts := 1; ; attempts++ {
+		d := backoff.Exponential(query.BaseBackoff, query.MaxBackoff, attempts)
+		if d <= 0 || d >= query.MaxBackoff {
+			due = due.Or("attempts >= ? AND updated_at < ?", attempts, now.Add(-d))
+			break
+		}
+		due = due.Or("attempts = ? AND updated_at < ?", attempts, now.Add(-d))
+	}
+
+	var payments []model.Payment
+	err := tx.Where("status = ?", model.PaymentPending).
+		Where("claimed_until IS NULL OR claimed_until < ?", now).
+		Where("updated_at < ?", now.Add(-query.StuckAfter)).
+		Where(due).
+		Order("updated_at").
+		Limit(query.Limit).
+		Find(&payments).Error
+	return payments, err
+}
+
+// ListExpiredAuthorizations returns unclaimed AUTHORIZED payments authorized before
+// authorizedBefore, oldest first
+func (r *PaymentRepository) ListExpiredAuthorizations(ctx context.Context, authorizedBefore time.Time, limit int) ([]model.Payment, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	var payments []model.Payment
+	err := tx.Where("status = ? AND authorized_at < ?", model.PaymentAuthorized, authorizedBefore).
+		Where("claimed_until IS NULL OR claimed_until < ?", time.Now()).
+		Order("authorized_at").
+		Limit(limit).
+		Find(&payments).Error
+	return payments, err
+}
+
+// recordHistory writes a status transition with the transaction that applies it.
+// Actor and request id are taken from ctx.
+func recordHistory(ctx context.Context, tx *gorm.DB, paymentID uuid.UUID, from, to model.PaymentStatus, reason string) error {
+	return tx.Create(&model.PaymentStatusHistory{
+		PaymentID:  paymentID,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     reason,
+		Actor:      utils.ActorFromContext(ctx),
+		RequestID:  utils.RequestIDFromContext(ctx),
+		CreatedAt:  time.Now(),
+	}).Error
 }
 
 // Optional: build response helper
 func BuildCreateResponse(ctx context.Context, p *model.Payment) *model.CreatePaymentResponse {
 	return model.NewCreatePaymentResponse(p, utils.NewMetaData(ctx))
 }
+
+// CountByStatus returns the number of payments in every status
+func (r *PaymentRepository) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := tx.Model(&model.Payment{}).Select("status, count(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
@@ -18,6 +18,7 @@ func NewPGRepo(db *gorm.DB) PGInterface {
 type PGInterface interface {
 	GetRepo() *gorm.DB
 	DBWithTimeout(ctx context.Context) (*gorm.DB, context.CancelFunc)
+	Ping(ctx context.Context) error
 }
 
 func (r *RepoPG) GetRepo() *gorm.DB {
@@ -28,3 +29,12 @@ func (r *RepoPG) DBWithTimeout(ctx context.Context) (*gorm.DB, context.CancelFun
 	ctx, cancel := context.WithTimeout(ctx, utils.GeneralQueryTimeout)
 	return r.db.WithContext(ctx), cancel
 }
+
+// Ping checks the database can be reached over the connection pool
+func (r *RepoPG) Ping(ctx context.Context) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,210 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	model "payment/internal/models"
+	pgGorm "payment/internal/repositories/pg-gorm"
+	"payment/pkg/core/money"
+	"time"
+)
+
+var (
+	// ErrRefundAmount is returned when a refund is not positive or exceeds the refundable amount
+	ErrRefundAmount = errors.New("refund amount exceeds refundable amount")
+	// ErrNotRefundable is returned when the payment has nothing captured to refund
+	ErrNotRefundable = errors.New("payment is not refundable in its current status")
+	// ErrRefundMismatch is returned when an idempotency key is reused for a different refund
+	ErrRefundMismatch = errors.New("refund data mismatch for existing idempotency key")
+)
+
+type RefundRepository struct {
+	db pgGorm.PGInterface
+}
+
+func NewRefundRepository(newPgRepo pgGorm.PGInterface) *RefundRepository {
+	return &RefundRepository{db: newPgRepo}
+}
+
+type RefundRepoInterface interface {
+	CreateOrGetRefund(ctx context.Context, paymentID string, req *model.CreateRefundRequest) (*model.Refund, *model.Payment, bool, error)
+	ClaimRefund(ctx context.Context, refundID string, lease time.Duration) (*model.Refund, bool, error)
+	CompleteRefund(ctx context.Context, refundID string, gatewayRef string) (*model.Refund, *model.Payment, error)
+	FailRefund(ctx context.Context, refundID string, reason string) error
+	ListStuckRefunds(ctx context.Context, stuckAfter time.Duration, limit int) ([]model.Refund, error)
+}
+
+// CreateOrGetRefund reserves amount on the payment and stores a PENDING refund.
+// When the idempotency key already exists the stored refund is returned instead.
+// The payment row is locked so concurrent refunds cannot exceed the captured amount.
+func (r *RefundRepository) CreateOrGetRefund(
+	ctx context.Context,
+	paymentID string,
+	req *model.CreateRefundRequest) (
+	*model.Refund, *model.Payment, bool, error) {
+
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	var (
+		payment model.Payment
+		refund  model.Refund
+		created bool
+	)
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", paymentID).First(&payment).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("idempotency_key = ?", req.IdempotencyKey).First(&refund).Error
+		if err == nil {
+			if refund.PaymentID != payment.ID || refund.Amount != req.Amount || refund.Currency != req.Currency {
+				return ErrRefundMismatch
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if payment.Status != model.PaymentCaptured && payment.Status != model.PaymentPartiallyCaptured {
+			return ErrNotRefundable
+		}
+		if req.Currency != payment.Currency {
+			return money.ErrCurrencyMismatch
+		}
+		if req.Amount <= 0 || req.Amount > payment.RefundableAmount() {
+			return ErrRefundAmount
+		}
+
+		refund = model.Refund{
+			PaymentID:      payment.ID,
+			IdempotencyKey: req.IdempotencyKey,
+			Amount:         req.Amount,
+			Currency:       req.Currency,
+			Reason:         req.Reason,
+			Status:         model.RefundPending,
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return err
+		}
+
+		payment.RefundedAmount += req.Amount
+		created = true
+		return tx.Model(&payment).Update("refunded_amount", payment.RefundedAmount).Error
+	})
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return &refund, &payment, created, nil
+}
+
+// ClaimRefund takes exclusive ownership of a PENDING refund for lease so that a single
+// caller drives the gateway. It returns the stored refund and whether it was claimed, a
+// refund claimed by another caller or no longer PENDING is returned unclaimed.
+func (r *RefundRepository) ClaimRefund(ctx context.Context, refundID string, lease time.Duration) (*model.Refund, bool, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	res := tx.Model(&model.Refund{}).
+		Where("id = ? AND status = ?", refundID, model.RefundPending).
+		Where("claimed_until IS NULL OR claimed_until < ?", now).
+		Update("claimed_until", now.Add(lease))
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+
+	var refund model.Refund
+	if err := tx.Where("id = ?", refundID).First(&refund).Error; err != nil {
+		return nil, false, err
+	}
+	return &refund, res.RowsAffected == 1, nil
+}
+
+// CompleteRefund marks a PENDING refund as SUCCEEDED and writes the payment.refunded
+// event to the outbox in the same transaction. A refund completed by another caller is
+// returned as stored and no event is written.
+func (r *RefundRepository) CompleteRefund(ctx context.Context, refundID string, gatewayRef string) (*model.Refund, *model.Payment, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	var (
+		refund  model.Refund
+		payment model.Payment
+	)
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.Refund{}).
+			Where("id = ? AND status = ?", refundID, model.RefundPending).
+			Updates(map[string]interface{}{
+				"status":        model.RefundSucceeded,
+				"gateway_ref":   gatewayRef,
+				"claimed_until": nil,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if err := tx.Where("id = ?", refundID).First(&refund).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", refund.PaymentID).First(&payment).Error; err != nil {
+			return err
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+
+		evt, err := model.NewOutboxEvent(payment.ID.String(), model.EventTypePaymentRefunded, model.NewRefundEvent(&refund, &payment))
+		if err != nil {
+			return err
+		}
+		return enqueueOutbox(ctx, tx, evt)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &refund, &payment, nil
+}
+
+// FailRefund marks a PENDING refund as FAILED and releases its reserved amount
+func (r *RefundRepository) FailRefund(ctx context.Context, refundID string, reason string) error {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	return tx.Transaction(func(tx *gorm.DB) error {
+		var refund model.Refund
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", refundID, model.RefundPending).First(&refund).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&refund).Updates(map[string]interface{}{
+			"status":        model.RefundFailed,
+			"last_error":    reason,
+			"claimed_until": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Payment{}).Where("id = ?", refund.PaymentID).
+			Update("refunded_amount", gorm.Expr("refunded_amount - ?", refund.Amount)).Error
+	})
+}
+
+// ListStuckRefunds returns PENDING refunds unchanged for stuckAfter and not claimed by a
+// caller, with their payment, oldest first
+func (r *RefundRepository) ListStuckRefunds(ctx context.Context, stuckAfter time.Duration, limit int) ([]model.Refund, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	var refunds []model.Refund
+	err := tx.Preload("Payment").
+		Where("status = ? AND updated_at < ?", model.RefundPending, now.Add(-stuckAfter)).
+		Where("claimed_until IS NULL OR claimed_until < ?", now).
+		Order("updated_at").
+		Limit(limit).
+		Find(&refunds).Error
+	return refunds, err
+}
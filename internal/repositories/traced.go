@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	model "payment/internal/models"
+	"payment/pkg/core/tracing"
+	"payment/pkg/http/paging"
+	"time"
+)
+
+// TracedPaymentRepository wraps every call of a payment repository in a span, the SQL
+// statements it runs show up as child spans
+type TracedPaymentRepository struct {
+	next PaymentRepoInterface
+}
+
+func NewTracedPaymentRepository(next PaymentRepoInterface) *TracedPaymentRepository {
+	return &TracedPaymentRepository{next: next}
+}
+
+func (r *TracedPaymentRepository) CreateOrGetPayment(ctx context.Context, req *model.CreatePaymentRequest) (*model.Payment, bool, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRepository.CreateOrGetPayment")
+	p, created, err := r.next.CreateOrGetPayment(ctx, req)
+	span.SetAttributes(attribute.Bool("payment.created", created))
+	tracing.End(span, err)
+	return p, created, err
+}
+
+func (r *TracedPaymentRepository) UpdateStatus(ctx context.Context, paymentID string, change model.StatusChange, events ...*model.OutboxEvent) error {
+	ctx, span := tracing.Start(ctx, "PaymentRepository.UpdateStatus",
+		attribute.String("payment.id", paymentID), attribute.String("payment.status", string(change.Status)))
+	err := r.next.UpdateStatus(ctx, paymentID, change, events...)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *TracedPaymentRepository) ClaimPayment(ctx context.Context, paymentID string, version int64, lease time.Duration) (*model.Payment, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRepository.ClaimPayment", attribute.String("payment.id", paymentID))
+	p, err := r.next.ClaimPayment(ctx, paymentID, version, lease)
+	tracing.End(span, err)
+	return p, err
+}
+
+func (r *TracedPaymentRepository) ReleaseClaim(ctx context.Context, paymentID string, version int64) error {
+	ctx, span := tracing.Start(ctx, "PaymentRepository.ReleaseClaim", attribute.String("payment.id", paymentID))
+	err := r.next.ReleaseClaim(ctx, paymentID, version)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *TracedPaymentRepository) GetPayment(ctx context.Context, paymentID string) (*model.Payment, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRepository.GetPayment", attribute.String("payment.id", paymentID))
+	p, err := r.next.GetPayment(ctx, paymentID)
+	tracing.End(span, err)
+	return p, err
+}
+
+func (r *TracedPaymentRepository) GetPaymentByIdempotencyKey(ctx context.Context, idempotencyKey string) (*model.Payment, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRepository.GetPaymentByIdempotencyKey")
+	p, err := r.next.GetPaymentByIdempotencyKey(ctx, idempotencyKey)
+	tracing.End(span, err)
+	return p, err
+}
+
+func (r *TracedPaymentRepository) ListPayments(ctx context.Context, filter *model.PaymentFilter, pager *paging.Pager) ([]model.Payment, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRepository.ListPayments")
+	payments, err := r.next.ListPayments(ctx, filter, pager)
+	tracing.End(span, err)
+	return payments, err
+}
+
+func (r *TracedPaymentRepository) GetPaymentHistory(ctx context.Context, paymentID string) ([]model.PaymentStatusHistory, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRepository.GetPaymentHistory", attribute.String("payment.id", paymentID))
+	history, err := r.next.GetPaymentHistory(ctx, paymentID)
+	tracing.End(span, err)
+	return history, err
+}
+
+func (r *TracedPaymentRepository) RecordCapture(ctx context.Context, paymentID string, version int64, amount int64) (*model.Payment, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRepository.RecordCapture", attribute.String("payment.id", paymentID))
+	p, err := r.next.RecordCapture(ctx, paymentID, version, amount)
+	tracing.End(span, err)
+	return p, err
+}
+
+func (r *TracedPaymentRepository) ListStuckPending(ctx context.Context, query StuckPaymentQuery) ([]model.Payment, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRepository.ListStuckPending")
+	payments, err := r.next.ListStuckPending(ctx, query)
+	tracing.End(span, err)
+	return payments, err
+}
+
+func (r *TracedPaymentRepository) ListExpiredAuthorizations(ctx context.Context, authorizedBefore time.Time, limit int) ([]model.Payment, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRepository.ListExpiredAuthorizations")
+	payments, err := r.next.ListExpiredAuthorizations(ctx, authorizedBefore, limit)
+	tracing.End(span, err)
+	return payments, err
+}
+
+// TracedRefundRepository is the refund counterpart of TracedPaymentRepository
+type TracedRefundRepository struct {
+	next RefundRepoInterface
+}
+
+func NewTracedRefundRepository(next RefundRepoInterface) *TracedRefundRepository {
+	return &TracedRefundRepository{next: next}
+}
+
+func (r *TracedRefundRepository) CreateOrGetRefund(ctx context.Context, paymentID string, req *model.CreateRefundRequest) (*model.Refund, *model.Payment, bool, error) {
+	ctx, span := tracing.Start(ctx, "RefundRepository.CreateOrGetRefund", attribute.String("payment.id", paymentID))
+	refund, p, created, err := r.next.CreateOrGetRefund(ctx, paymentID, req)
+	span.SetAttributes(attribute.Bool("refund.created", created))
+	tracing.End(span, err)
+	return refund, p, created, err
+}
+
+func (r *TracedRefundRepository) ClaimRefund(ctx context.Context, refundID string, lease time.Duration) (*model.Refund, bool, error) {
+	ctx, span := tracing.Start(ctx, "RefundRepository.ClaimRefund", attribute.String("refund.id", refundID))
+	refund, claimed, err := r.next.ClaimRefund(ctx, refundID, lease)
+	span.SetAttributes(attribute.Bool("refund.claimed", claimed))
+	tracing.End(span, err)
+	return refund, claimed, err
+}
+
+func (r *TracedRefundRepository) ListStuckRefunds(ctx context.Context, stuckAfter time.Duration, limit int) ([]model.Refund, error) {
+	ctx, span := tracing.Start(ctx, "RefundRepository.ListStuckRefunds")
+	refunds, err := r.next.ListStuckRefunds(ctx, stuckAfter, limit)
+	tracing.End(span, err)
+	return refunds, err
+}
+
+func (r *TracedRefundRepository) CompleteRefund(ctx context.Context, refundID string, gatewayRef string) (*model.Refund, *model.Payment, error) {
+	ctx, span := tracing.Start(ctx, "RefundRepository.CompleteRefund", attribute.String("refund.id", refundID))
+	refund, p, err := r.next.CompleteRefund(ctx, refundID, gatewayRef)
+	tracing.End(span, err)
+	return refund, p, err
+}
+
+func (r *TracedRefundRepository) FailRefund(ctx context.Context, refundID string, reason string) error {
+	ctx, span := tracing.Start(ctx, "RefundRepository.FailRefund", attribute.String("refund.id", refundID))
+	err := r.next.FailRefund(ctx, refundID, reason)
+	tracing.End(span, err)
+	return err
+}
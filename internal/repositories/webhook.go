@@ -0,0 +1,206 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	model "payment/internal/models"
+	pgGorm "payment/internal/repositories/pg-gorm"
+	"payment/pkg/http/paging"
+	"time"
+)
+
+type WebhookRepository struct {
+	db pgGorm.PGInterface
+}
+
+func NewWebhookRepository(newPgRepo pgGorm.PGInterface) *WebhookRepository {
+	return &WebhookRepository{db: newPgRepo}
+}
+
+type WebhookRepoInterface interface {
+	CreateEndpoint(ctx context.Context, endpoint *model.WebhookEndpoint) error
+	GetEndpoint(ctx context.Context, id string) (*model.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, merchantID string, pager *paging.Pager) ([]model.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, merchantID, id string) error
+	ListDeliveries(ctx context.Context, endpointID string, pager *paging.Pager) ([]model.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, endpointID, deliveryID string) (*model.WebhookDelivery, error)
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, delivery *model.WebhookDelivery, attempt *model.WebhookDeliveryAttempt) error
+}
+
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, endpoint *model.WebhookEndpoint) error {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	return tx.Create(endpoint).Error
+}
+
+func (r *WebhookRepository) GetEndpoint(ctx context.Context, id string) (*model.WebhookEndpoint, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	var endpoint model.WebhookEndpoint
+	if err := tx.Where("id = ?", id).First(&endpoint).Error; err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (r *WebhookRepository) ListEndpoints(ctx context.Context, merchantID string, pager *paging.Pager) ([]model.WebhookEndpoint, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	query := tx.Model(&model.WebhookEndpoint{}).Where("merchant_id = ?", merchantID)
+	if pager.Sort == "" {
+		pager.Sort = "-created_at"
+	}
+
+	var endpoints []model.WebhookEndpoint
+	if err := pager.DoQuery(&endpoints, query.Session(&gorm.Session{})).Error; err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// DeleteEndpoint soft deletes an endpoint of merchantID. Pending deliveries to it fail on
+// their next attempt.
+func (r *WebhookRepository) DeleteEndpoint(ctx context.Context, merchantID, id string) error {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	res := tx.Where("id = ? AND merchant_id = ?", id, merchantID).Delete(&model.WebhookEndpoint{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, endpointID string, pager *paging.Pager) ([]model.WebhookDelivery, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	if pager.Sort == "" {
+		pager.Sort = "-created_at"
+	}
+
+	var deliveries []model.WebhookDelivery
+	query := tx.Model(&model.WebhookDelivery{}).Where("endpoint_id = ?", endpointID)
+	if err := pager.DoQuery(&deliveries, query.Session(&gorm.Session{})).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery queues a delivery of endpointID again with a fresh set of attempts,
+// whatever its current status
+func (r *WebhookRepository) ReplayDelivery(ctx context.Context, endpointID, deliveryID string) (*model.WebhookDelivery, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	var delivery model.WebhookDelivery
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.WebhookDelivery{}).
+			Where("id = ? AND endpoint_id = ?", deliveryID, endpointID).
+			Updates(map[string]interface{}{
+				"status":          model.WebhookDeliveryPending,
+				"attempts":        0,
+				"last_error":      "",
+				"next_attempt_at": time.Now(),
+				"delivered_at":    nil,
+				"updated_at":      time.Now(),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("id = ?", deliveryID).First(&delivery).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ClaimDue locks up to limit due deliveries and pushes their next attempt past lease, so
+// other dispatchers skip them while the requests are in flight without holding a
+// transaction open. Endpoints are loaded with the deliveries, deleted ones are left nil.
+func (r *WebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	var deliveries []model.WebhookDelivery
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.WebhookDeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, 0, len(deliveries))
+		for i := range deliveries {
+			ids = append(ids, deliveries[i].ID)
+			deliveries[i].NextAttemptAt = now.Add(lease)
+		}
+		return tx.Model(&model.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil || len(deliveries) == 0 {
+		return nil, err
+	}
+
+	endpointIDs := make([]uuid.UUID, 0, len(deliveries))
+	for i := range deliveries {
+		endpointIDs = append(endpointIDs, deliveries[i].EndpointID)
+	}
+	var endpoints []model.WebhookEndpoint
+	if err := tx.Where("id IN ?", endpointIDs).Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*model.WebhookEndpoint, len(endpoints))
+	for i := range endpoints {
+		byID[endpoints[i].ID] = &endpoints[i]
+	}
+	for i := range deliveries {
+		deliveries[i].Endpoint = byID[deliveries[i].EndpointID]
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt saves the outcome of a delivery together with its delivery log entry
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, delivery *model.WebhookDelivery, attempt *model.WebhookDeliveryAttempt) error {
+	tx, cancel := r.db.DBWithTimeout(ctx)
+	defer cancel()
+
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(attempt).Error; err != nil {
+			return err
+		}
+		return tx.Model(delivery).
+			Select("status", "attempts", "last_error", "next_attempt_at", "delivered_at", "updated_at").
+			Updates(delivery).Error
+	})
+}
+
+// enqueueWebhookDeliveries fans evt out to every endpoint subscribed to its type, in the
+// transaction that writes evt
+func enqueueWebhookDeliveries(tx *gorm.DB, evt *model.OutboxEvent) error {
+	return tx.Exec(`INSERT INTO webhook_deliveries
+			(endpoint_id, event_id, event_type, event_time, schema_version, trace_id, payload, status, next_attempt_at)
+		SELECT id, ?, ?, ?, ?, ?, ?::jsonb, ?, ? FROM webhook_endpoints
+		WHERE deleted_at IS NULL AND (event_types = '[]'::jsonb OR event_types @> jsonb_build_array(?::text))
+		ON CONFLICT (endpoint_id, event_id) DO NOTHING`,
+		evt.EventID, evt.EventType, evt.CreatedAt, evt.SchemaVersion, evt.TraceID, evt.Payload,
+		model.WebhookDeliveryPending, evt.CreatedAt, evt.EventType,
+	).Error
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"gorm.io/gorm"
+	"net"
+	"payment/internal/gateway"
+	model "payment/internal/models"
+	"payment/internal/repositories"
+	"payment/pkg/core/money"
+	"payment/pkg/http/utils/app_errors"
+)
+
+// Reasons reported in the ErrorInfo detail of gRPC errors and in the HTTP error body
+const (
+	ReasonPaymentNotFound     = "PAYMENT_NOT_FOUND"
+	ReasonRefundNotFound      = "REFUND_NOT_FOUND"
+	ReasonIdempotencyMismatch = "IDEMPOTENCY_KEY_MISMATCH"
+	ReasonInvalidTransition   = "INVALID_STATUS_TRANSITION"
+	ReasonConcurrentUpdate    = "CONCURRENT_UPDATE"
+	ReasonNotRefundable       = "PAYMENT_NOT_REFUNDABLE"
+	ReasonGatewayDeclined     = "GATEWAY_DECLINED"
+	ReasonGatewayTimeout      = "GATEWAY_TIMEOUT"
+	ReasonGatewayUnavailable  = "GATEWAY_UNAVAILABLE"
+	ReasonStoreUnavailable    = "STORE_UNAVAILABLE"
+	ReasonWebhookNotFound     = "WEBHOOK_NOT_FOUND"
+	ReasonDeliveryNotFound    = "WEBHOOK_DELIVERY_NOT_FOUND"
+)
+
+// invalidField builds a validation error for a single request field
+func invalidField(field string, description string) *app_errors.ResponseError {
+	return app_errors.Validation(app_errors.FieldViolation{Field: field, Description: description})
+}
+
+// repoError converts repository failures into application errors
+func repoError(err error) *app_errors.ResponseError {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return app_errors.NotFound(ReasonPaymentNotFound)
+	case errors.Is(err, repositories.ErrIdempotencyMismatch):
+		return app_errors.AlreadyExists(ReasonIdempotencyMismatch, err.Error())
+	case errors.Is(err, model.ErrInvalidTransition):
+		return app_errors.Conflict(ReasonInvalidTransition, err.Error())
+	case errors.Is(err, model.ErrConcurrentUpdate):
+		return app_errors.Aborted(ReasonConcurrentUpdate, err.Error())
+	case errors.Is(err, repositories.ErrCaptureAmount):
+		return invalidField("amount", err.Error())
+	default:
+		return storeError(err)
+	}
+}
+
+// refundRepoError converts refund repository failures into application errors
+func refundRepoError(err error) *app_errors.ResponseError {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return app_errors.NotFound(ReasonRefundNotFound)
+	case errors.Is(err, repositories.ErrRefundAmount):
+		return invalidField("amount.amount", err.Error())
+	case errors.Is(err, money.ErrCurrencyMismatch):
+		return invalidField("amount.currency", err.Error())
+	case errors.Is(err, repositories.ErrNotRefundable):
+		return app_errors.Conflict(ReasonNotRefundable, err.Error())
+	case errors.Is(err, repositories.ErrRefundMismatch):
+		return app_errors.AlreadyExists(ReasonIdempotencyMismatch, err.Error())
+	default:
+		return storeError(err)
+	}
+}
+
+// webhookRepoError reports a missing webhook row with notFoundReason
+func webhookRepoError(err error, notFoundReason string) *app_errors.ResponseError {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return app_errors.NotFound(notFoundReason)
+	}
+	return storeError(err)
+}
+
+// storeError reports a database that cannot be reached as unavailable so callers may
+// retry, any other failure is internal
+func storeError(err error) *app_errors.ResponseError {
+	if storeUnavailable(err) {
+		return app_errors.Unavailable(ReasonStoreUnavailable)
+	}
+	return app_errors.Internal()
+}
+
+// storeUnavailable reports whether err means the database could not be reached in time
+func storeUnavailable(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr)
+}
+
+// gatewayError converts gateway failures into application errors
+func gatewayError(err error) *app_errors.ResponseError {
+	if errors.Is(err, gateway.ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return app_errors.GatewayTimeout(ReasonGatewayTimeout)
+	}
+	return app_errors.Unavailable(ReasonGatewayUnavailable)
+}
+
+// gatewayDeclined reports an operation the gateway refused
+func gatewayDeclined(reason string) *app_errors.ResponseError {
+	return app_errors.Conflict(ReasonGatewayDeclined, reason)
+}
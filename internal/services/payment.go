@@ -3,124 +3,607 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"payment/internal/gateway"
 	model "payment/internal/models"
 	"payment/internal/repositories"
-	"payment/pkg/core/kafka/payment"
+	"payment/pkg/core/backoff"
 	"payment/pkg/core/logger"
+	"payment/pkg/core/money"
+	"payment/pkg/core/tracing"
+	"payment/pkg/http/paging"
+	"payment/pkg/http/utils"
 	"payment/pkg/http/utils/app_errors"
 	pb "payment/pkg/proto/paymentpb"
 	"time"
 )
 
+// PaymentReconciler finalizes payments left PENDING
+type PaymentReconciler interface {
+	Reconcile(ctx context.Context, payment *model.Payment, maxAttempts int) error
+}
+
+// PaymentExpirer expires authorizations that were never captured
+type PaymentExpirer interface {
+	Expire(ctx context.Context, payment *model.Payment) error
+}
+
 type PaymentProcessor interface {
 	Process(ctx context.Context, req *pb.PayRequest) (*pb.PayResponse, error)
+	Capture(ctx context.Context, req *pb.CaptureRequest) (*pb.CaptureResponse, error)
+	Void(ctx context.Context, req *pb.VoidRequest) (*pb.VoidResponse, error)
+}
+
+// PaymentReader exposes read-only payment queries shared by the gRPC and HTTP APIs
+type PaymentReader interface {
+	GetPayment(ctx context.Context, paymentID string) (*model.Payment, error)
+	GetPaymentByIdempotencyKey(ctx context.Context, idempotencyKey string) (*model.Payment, error)
+	ListPayments(ctx context.Context, filter *model.PaymentFilter, pager *paging.Pager) ([]model.Payment, error)
+	GetPaymentHistory(ctx context.Context, paymentID string) ([]model.PaymentStatusHistory, error)
 }
 
+// A capture made at the gateway is stored with up to captureRecordAttempts writes
+const (
+	captureRecordAttempts   = 5
+	captureRecordBackoff    = 200 * time.Millisecond
+	captureRecordMaxBackoff = 2 * time.Second
+)
+
+// paymentClaimLease bounds how long a Pay caller owns a PENDING payment, it must outlast
+// a gateway authorization so a crashed caller does not block retries forever
+const paymentClaimLease = time.Minute
+
 type PaymentService struct {
-	repo     repositories.PaymentRepoInterface
-	producer payment.Producer
+	repo    repositories.PaymentRepoInterface
+	gateway gateway.Gateway
+	// idempotencyTTL is how long a Pay idempotency key is honoured, zero never expires
+	idempotencyTTL time.Duration
 }
 
-func NewPaymentService(repo repositories.PaymentRepoInterface, producer payment.Producer) *PaymentService {
-	return &PaymentService{repo: repo, producer: producer}
+func NewPaymentService(repo repositories.PaymentRepoInterface, gw gateway.Gateway, idempotencyTTL time.Duration) *PaymentService {
+	return &PaymentService{repo: repo, gateway: gw, idempotencyTTL: idempotencyTTL}
 }
 
+// Process creates the payment for req, or replays the one stored under its event id,
+// authorizes a new or still PENDING payment with the gateway and returns its status
 func (s *PaymentService) Process(ctx context.Context, req *pb.PayRequest) (*pb.PayResponse, error) {
+	ctx, span := tracing.Start(ctx, "PaymentService.Process",
+		attribute.String("order_id", req.GetOrderId()),
+		attribute.String("event_id", req.GetEventId()),
+	)
+	resp, err := s.process(ctx, req)
+	if resp != nil {
+		span.SetAttributes(
+			attribute.String("payment.id", resp.PaymentId),
+			attribute.String("payment.status", resp.Status),
+		)
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+func (s *PaymentService) process(ctx context.Context, req *pb.PayRequest) (*pb.PayResponse, error) {
 
 	log := logger.WithTag("PaymentService|Process")
 
 	if req == nil {
-		err := app_errors.AppError(app_errors.StatusInternalServerError, app_errors.StatusInternalServerError)
+		err := app_errors.Validation()
 		logger.LogError(log, err, "nil request")
 		return nil, err
 	}
 
-	if req.Amount <= 0 {
-		err := app_errors.AppError(app_errors.StatusInternalServerError, app_errors.StatusInternalServerError)
-		logger.LogError(log, err, "invalid amount")
-		return nil, err
+	var violations []app_errors.FieldViolation
+	amount, err := moneyFromPB(req.Amount)
+	if err != nil || !amount.IsPositive() {
+		violations = append(violations, app_errors.FieldViolation{Field: "amount", Description: "must be a positive amount in a known currency"})
 	}
 	if req.EventId == "" {
-		err := app_errors.AppError(app_errors.StatusInternalServerError, app_errors.StatusInternalServerError)
-		logger.LogError(log, err, "missing event ID for idempotency")
+		violations = append(violations, app_errors.FieldViolation{Field: "event_id", Description: "is required for idempotency"})
+	}
+	if len(violations) > 0 {
+		err := app_errors.Validation(violations...)
+		logger.LogError(log, err, "invalid pay request")
 		return nil, err
 	}
 
 	createReq := &model.CreatePaymentRequest{
 		OrderID:        req.OrderId,
 		IdempotencyKey: req.EventId,
-		Amount:         req.Amount,
+		Amount:         amount.Amount,
+		Currency:       amount.Currency,
+		CardToken:      req.CardToken,
+	}
+	if s.idempotencyTTL > 0 {
+		createReq.ExpiresAt = utils.ToPointer(time.Now().Add(s.idempotencyTTL))
 	}
 
 	payment, created, err := s.repo.CreateOrGetPayment(ctx, createReq)
 	if err != nil {
 		logger.LogError(log, err, "failed to create or get payment")
-		return nil, app_errors.AppError(app_errors.StatusInternalServerError, app_errors.StatusInternalServerError)
-
+		return nil, repoError(err)
 	}
 
 	// Nếu đã tồn tại và đã có trạng thái cuối thì trả ngay
-	if !created && (payment.Status == model.PaymentAuthorized || payment.Status == model.PaymentDeclined) {
-		return &pb.PayResponse{
-			Message:   string(payment.Status),
-			PaymentId: payment.ID.String(),
-			Status:    string(payment.Status),
-		}, nil
+	if !created && payment.Status != model.PaymentPending {
+		return replayResponse(payment), nil
 	}
 
 	// Chỉ thực hiện gateway nếu vừa tạo hoặc vẫn ở trạng thái PENDING
 	if payment.Status == model.PaymentPending {
-		select {
-		case <-ctx.Done():
-			_ = s.repo.UpdateStatus(ctx, payment.ID.String(), model.PaymentDeclined, "context canceled")
-			return &pb.PayResponse{
-				Message:   string(payment.Status),
-				PaymentId: payment.ID.String(),
-				Status:    string(model.PaymentDeclined),
-			}, ctx.Err()
-		case <-time.After(100 * time.Millisecond):
+		// Claim the payment so that only one caller drives the gateway, the others
+		// get the in-flight or final result
+		claimed, err := s.repo.ClaimPayment(ctx, payment.ID.String(), payment.Version, paymentClaimLease)
+		if errors.Is(err, model.ErrConcurrentUpdate) {
+			return s.currentResult(ctx, payment.ID.String())
+		}
+		if err != nil {
+			logger.LogError(log, err, "failed to claim payment")
+			return nil, repoError(err)
 		}
+		payment = claimed
+
+		return s.authorize(ctx, payment)
+	}
+
+	return newPayResponse(payment), nil
+}
+
+// authorize drives the gateway for a PENDING payment claimed by the caller. Timeouts,
+// gateway failures and cancellations before an answer keep the payment PENDING and count
+// an attempt, so it can be retried.
+func (s *PaymentService) authorize(ctx context.Context, payment *model.Payment) (*pb.PayResponse, error) {
+	log := logger.WithCtx(ctx, "PaymentService|authorize").WithField("payment_id", payment.ID.String())
+
+	result, err := s.gateway.Authorize(ctx, gateway.AuthorizeRequest{
+		PaymentID: payment.ID.String(),
+		OrderID:   payment.OrderID,
+		CardToken: payment.CardToken,
+		Amount:    payment.Total(),
+	})
 
-		// Fake gateway success logic (có thể thay bằng gọi thật)
-		// This Fake gateway success using for replacing real payment gateway integration
-		gatewaySuccess := true
-
-		if gatewaySuccess {
-			_ = s.repo.UpdateStatus(ctx, payment.ID.String(), model.PaymentAuthorized, "")
-			payment.Status = model.PaymentAuthorized
-
-			evt := model.PaymentEvent{
-				PaymentID:      payment.ID.String(),
-				OrderID:        payment.OrderID,
-				IdempotencyKey: payment.IdempotencyKey,
-				Amount:         payment.Amount,
-				Status:         string(payment.Status),
-			}
-
-			payload, err := json.Marshal(evt)
-			if err != nil {
-				return nil, err
-			}
-
-			if err = s.producer.SendMessage(ctx, payment.ID.String(), string(payload)); err != nil {
-				log.Printf("failed to send payment event to kafka: %v", err)
-				return nil, err
-			}
-
-		} else {
-			_ = s.repo.UpdateStatus(ctx, payment.ID.String(), model.PaymentDeclined, "gateway fail")
-			payment.Status = model.PaymentDeclined
+	// The gateway answer is stored even when the caller went away meanwhile: an approval
+	// holds funds that must not be forgotten, and only a gateway decline declines
+	storeCtx := context.WithoutCancel(ctx)
+	switch {
+	case err == nil && !result.Approved:
+		return s.decline(storeCtx, payment, result.Reason)
+
+	case errors.Is(err, gateway.ErrTimeout) || (err != nil && ctx.Err() != nil):
+		// Outcome is unknown: keep the payment PENDING so the reconciler retries it
+		logger.LogError(log, err, "gateway authorize outcome unknown")
+		s.recordFailedAttempt(storeCtx, payment, err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
+		return nil, gatewayError(err)
+
+	case err != nil:
+		logger.LogError(log, err, "gateway authorize failed")
+		s.recordFailedAttempt(storeCtx, payment, err)
+		return nil, gatewayError(err)
+	}
+
+	payment.Status = model.PaymentAuthorized
+	payment.GatewayRef = result.Reference
+
+	evt, err := model.NewPaymentOutboxEvent(payment)
+	if err != nil {
+		logger.LogError(log, err, "failed to build payment event")
+		return nil, app_errors.Internal()
 	}
+	resp := newPayResponse(payment)
+	// The gateway reference is written with the status, an AUTHORIZED payment without
+	// one could not be captured, voided or refunded
+	change := model.StatusChange{
+		Status:     model.PaymentAuthorized,
+		Version:    payment.Version,
+		Response:   encodePayResponse(resp),
+		GatewayRef: result.Reference,
+	}
+	if err := s.repo.UpdateStatus(storeCtx, payment.ID.String(), change, evt); err != nil {
+		logger.LogError(log, err, "failed to update payment status")
+		return nil, repoError(err)
+	}
+	return resp, nil
+}
+
+// recordFailedAttempt counts a failed gateway attempt on a claimed payment and releases
+// the claim. When the write fails the claim lapses after its lease and the reconciler
+// retries the payment.
+func (s *PaymentService) recordFailedAttempt(ctx context.Context, payment *model.Payment, gatewayErr error) {
+	change := model.StatusChange{Status: model.PaymentPending, Error: gatewayErr.Error(), Version: payment.Version}
+	if err := s.repo.UpdateStatus(ctx, payment.ID.String(), change); err != nil {
+		logger.LogError(logger.WithCtx(ctx, "PaymentService|recordFailedAttempt").WithField("payment_id", payment.ID.String()),
+			err, "failed to record gateway attempt")
+	}
+}
 
+// decline moves a claimed payment to DECLINED and emits its event. Nothing is returned
+// with an error, the payment is only declined once the change is stored.
+func (s *PaymentService) decline(ctx context.Context, payment *model.Payment, reason string) (*pb.PayResponse, error) {
+	log := logger.WithCtx(ctx, "PaymentService|decline").WithField("payment_id", payment.ID.String())
+
+	payment.Status = model.PaymentDeclined
+	resp := newPayResponse(payment)
+
+	evt, err := model.NewPaymentOutboxEvent(payment)
+	if err != nil {
+		logger.LogError(log, err, "failed to build payment event")
+		return nil, app_errors.Internal()
+	}
+	change := model.StatusChange{
+		Status:   model.PaymentDeclined,
+		Error:    reason,
+		Version:  payment.Version,
+		Response: encodePayResponse(resp),
+	}
+	if err := s.repo.UpdateStatus(ctx, payment.ID.String(), change, evt); err != nil {
+		logger.LogError(log, err, "failed to decline payment")
+		return nil, repoError(err)
+	}
+	return resp, nil
+}
+
+// Reconcile re-drives a payment left PENDING, eg. by a crash between creation and the
+// gateway answer. Authorization is retried with the payment id as the gateway idempotency
+// reference until maxAttempts failed attempts, after which the payment is declined.
+// Payments claimed by another caller are left alone.
+func (s *PaymentService) Reconcile(ctx context.Context, payment *model.Payment, maxAttempts int) error {
+	log := logger.WithCtx(ctx, "PaymentService|Reconcile").WithField("payment_id", payment.ID.String())
+
+	claimed, err := s.repo.ClaimPayment(ctx, payment.ID.String(), payment.Version, paymentClaimLease)
+	if errors.Is(err, model.ErrConcurrentUpdate) {
+		return nil
+	}
+	if err != nil {
+		logger.LogError(log, err, "failed to claim payment")
+		return err
+	}
+
+	// Shutting the worker down must not cut the attempt short, it runs to completion
+	// within the claim lease
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), paymentClaimLease)
+	defer cancel()
+
+	if claimed.Attempts >= maxAttempts {
+		reason := fmt.Sprintf("gave up after %d gateway attempts: %s", claimed.Attempts, claimed.LastError)
+		_, err = s.decline(ctx, claimed, reason)
+		return err
+	}
+
+	_, err = s.authorize(ctx, claimed)
+	return err
+}
+
+// currentResult answers a Pay call that lost the claim with the payment's current state
+func (s *PaymentService) currentResult(ctx context.Context, paymentID string) (*pb.PayResponse, error) {
+	payment, err := s.repo.GetPayment(ctx, paymentID)
+	if err != nil {
+		logger.LogError(logger.WithTag("PaymentService|currentResult"), err, "failed to get payment")
+		return nil, repoError(err)
+	}
+	return replayResponse(payment), nil
+}
+
+func newPayResponse(payment *model.Payment) *pb.PayResponse {
 	return &pb.PayResponse{
-		Message:   string(model.PaymentAuthorized),
+		Message:   string(payment.Status),
+		PaymentId: payment.ID.String(),
+		Status:    string(payment.Status),
+	}
+}
+
+// encodePayResponse serializes the response replayed to idempotent retries
+func encodePayResponse(resp *pb.PayResponse) string {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return ""
+	}
+	return string(body)
+}
+
+// replayResponse returns the response stored when the payment reached its final state,
+// so a retry gets exactly what the original caller got even if the payment moved on since.
+// Payments without a stored response are answered from their current state.
+func replayResponse(payment *model.Payment) *pb.PayResponse {
+	if payment.Response != "" {
+		var resp pb.PayResponse
+		if err := json.Unmarshal([]byte(payment.Response), &resp); err == nil {
+			return &resp
+		}
+	}
+	return newPayResponse(payment)
+}
+
+func (s *PaymentService) Capture(ctx context.Context, req *pb.CaptureRequest) (*pb.CaptureResponse, error) {
+
+	log := logger.WithTag("PaymentService|Capture")
+
+	if req == nil || req.PaymentId == "" {
+		err := invalidField("payment_id", "is required")
+		logger.LogError(log, err, "invalid capture request")
+		return nil, err
+	}
+	if req.GetAmount().GetAmount() < 0 {
+		err := invalidField("amount.amount", "must not be negative")
+		logger.LogError(log, err, "invalid capture request")
+		return nil, err
+	}
+
+	payment, err := s.repo.GetPayment(ctx, req.PaymentId)
+	if err != nil {
+		logger.LogError(log, err, "failed to get payment")
+		return nil, repoError(err)
+	}
+
+	if !model.CanTransition(payment.Status, model.PaymentCaptured) {
+		err := app_errors.Conflict(ReasonInvalidTransition, "payment is not capturable in status "+string(payment.Status))
+		logger.LogError(log, err, "payment is not capturable in status "+string(payment.Status))
+		return nil, err
+	}
+
+	amount := money.Money{Amount: payment.RemainingAmount(), Currency: payment.Currency}
+	if req.GetAmount().GetAmount() != 0 {
+		if amount, err = moneyFromPB(req.Amount); err != nil {
+			err := invalidField("amount.currency", "must be a known ISO-4217 currency")
+			logger.LogError(log, err, "invalid capture currency")
+			return nil, err
+		}
+		if amount.Currency != payment.Currency {
+			err := invalidField("amount.currency", "must match the payment currency "+payment.Currency)
+			logger.LogError(log, err, "capture currency does not match payment currency")
+			return nil, err
+		}
+	}
+	if amount.Amount > payment.RemainingAmount() {
+		err := invalidField("amount.amount", repositories.ErrCaptureAmount.Error())
+		logger.LogError(log, err, "capture amount exceeds remaining authorized amount")
+		return nil, err
+	}
+
+	// Claim the payment before moving money, a concurrent capture or void loses the claim
+	// instead of succeeding at the gateway without being recorded
+	payment, err = s.claim(ctx, payment)
+	if err != nil {
+		logger.LogError(log, err, "failed to claim payment")
+		return nil, err
+	}
+
+	result, err := s.gateway.Capture(ctx, payment.GatewayRef, amount)
+	if err != nil {
+		logger.LogError(log, err, "gateway capture failed")
+		s.release(ctx, payment, err)
+		return nil, gatewayError(err)
+	}
+	if !result.Approved {
+		err := gatewayDeclined(result.Reason)
+		logger.LogError(log, err, "gateway declined capture")
+		s.release(ctx, payment, nil)
+		return nil, err
+	}
+
+	payment, err = s.recordCapture(ctx, payment, amount.Amount)
+	if err != nil {
+		logger.LogError(log, err, "failed to record capture")
+		return nil, repoError(err)
+	}
+
+	return &pb.CaptureResponse{
+		PaymentId:      payment.ID.String(),
+		Status:         string(payment.Status),
+		CapturedAmount: moneyToPB(payment.CapturedAmount, payment.Currency),
+	}, nil
+}
+
+func (s *PaymentService) Void(ctx context.Context, req *pb.VoidRequest) (*pb.VoidResponse, error) {
+
+	log := logger.WithTag("PaymentService|Void")
+
+	if req == nil || req.PaymentId == "" {
+		err := invalidField("payment_id", "is required")
+		logger.LogError(log, err, "invalid void request")
+		return nil, err
+	}
+
+	payment, err := s.repo.GetPayment(ctx, req.PaymentId)
+	if err != nil {
+		logger.LogError(log, err, "failed to get payment")
+		return nil, repoError(err)
+	}
+
+	if !model.CanTransition(payment.Status, model.PaymentVoided) {
+		err := app_errors.Conflict(ReasonInvalidTransition, "payment is not voidable in status "+string(payment.Status))
+		logger.LogError(log, err, "payment is not voidable in status "+string(payment.Status))
+		return nil, err
+	}
+
+	log.WithField("reason", req.Reason).Infof("voiding payment %s", req.PaymentId)
+
+	payment, err = s.claim(ctx, payment)
+	if err != nil {
+		logger.LogError(log, err, "failed to claim payment")
+		return nil, err
+	}
+
+	result, err := s.gateway.Void(ctx, payment.GatewayRef)
+	if err != nil {
+		logger.LogError(log, err, "gateway void failed")
+		s.release(ctx, payment, err)
+		return nil, gatewayError(err)
+	}
+	if !result.Approved {
+		err := gatewayDeclined(result.Reason)
+		logger.LogError(log, err, "gateway declined void")
+		s.release(ctx, payment, nil)
+		return nil, err
+	}
+
+	payment.Status = model.PaymentVoided
+	evt, err := model.NewPaymentOutboxEvent(payment)
+	if err != nil {
+		logger.LogError(log, err, "failed to build payment event")
+		return nil, app_errors.Internal()
+	}
+	change := model.StatusChange{Status: model.PaymentVoided, Reason: req.Reason, Version: payment.Version}
+	if err := s.repo.UpdateStatus(ctx, req.PaymentId, change, evt); err != nil {
+		logger.LogError(log, err, "failed to update payment status")
+		return nil, repoError(err)
+	}
+
+	return &pb.VoidResponse{
 		PaymentId: payment.ID.String(),
 		Status:    string(payment.Status),
 	}, nil
 }
 
-// Helpers (cần tự cài đặt parseUUID)
-func parseUUID(s string) (u [16]byte) {
-	return
+// recordCapture stores a capture the gateway already made. The write outlives the caller
+// and is retried, a capture left unrecorded would be made again by the next capture call.
+func (s *PaymentService) recordCapture(ctx context.Context, payment *model.Payment, amount int64) (*model.Payment, error) {
+	ctx = context.WithoutCancel(ctx)
+	for attempt := 1; ; attempt++ {
+		captured, err := s.repo.RecordCapture(ctx, payment.ID.String(), payment.Version, amount)
+		if err == nil {
+			return captured, nil
+		}
+		// Only a store that could not be reached is worth retrying, the other errors
+		// are answers about the payment
+		if attempt >= captureRecordAttempts || !storeUnavailable(err) {
+			return nil, err
+		}
+		logger.LogError(logger.WithCtx(ctx, "PaymentService|recordCapture").WithField("payment_id", payment.ID.String()),
+			err, "failed to record capture, retrying")
+		time.Sleep(backoff.Exponential(captureRecordBackoff, captureRecordMaxBackoff, attempt))
+	}
+}
+
+// claim takes the payment for a capture or void at the version it was read, a payment
+// claimed or changed by another caller is a conflict
+func (s *PaymentService) claim(ctx context.Context, payment *model.Payment) (*model.Payment, error) {
+	claimed, err := s.repo.ClaimPayment(ctx, payment.ID.String(), payment.Version, paymentClaimLease)
+	if err != nil {
+		return nil, repoError(err)
+	}
+	return claimed, nil
+}
+
+// release gives up a claim after the gateway refused the operation. After a timeout the
+// outcome is unknown, so the claim is kept until its lease lapses rather than letting a
+// retry repeat the operation right away.
+func (s *PaymentService) release(ctx context.Context, payment *model.Payment, gatewayErr error) {
+	if errors.Is(gatewayErr, gateway.ErrTimeout) {
+		return
+	}
+	if err := s.repo.ReleaseClaim(context.WithoutCancel(ctx), payment.ID.String(), payment.Version); err != nil {
+		logger.LogError(logger.WithCtx(ctx, "PaymentService|release").WithField("payment_id", payment.ID.String()), err, "failed to release payment claim")
+	}
+}
+
+// Expire releases the hold of an AUTHORIZED payment whose authorization lapsed, then moves
+// it to EXPIRED and emits its event. The payment stays AUTHORIZED when the gateway does not
+// release the hold, so the expirer tries again. A payment claimed or changed since it was
+// listed is left alone.
+func (s *PaymentService) Expire(ctx context.Context, payment *model.Payment) error {
+	log := logger.WithCtx(ctx, "PaymentService|Expire").WithField("payment_id", payment.ID.String())
+
+	claimed, err := s.repo.ClaimPayment(ctx, payment.ID.String(), payment.Version, paymentClaimLease)
+	if errors.Is(err, model.ErrConcurrentUpdate) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := s.gateway.Void(ctx, claimed.GatewayRef)
+	switch {
+	case errors.Is(err, gateway.ErrUnknownReference):
+		// The gateway already dropped the authorization, there is no hold left to release
+		log.Infof("gateway no longer knows authorization %s", claimed.GatewayRef)
+	case err != nil:
+		s.release(ctx, claimed, err)
+		return fmt.Errorf("release authorization: %w", err)
+	case !result.Approved:
+		s.release(ctx, claimed, nil)
+		return fmt.Errorf("gateway refused to release authorization: %s", result.Reason)
+	}
+
+	expired := *claimed
+	expired.Status = model.PaymentExpired
+	evt, err := model.NewPaymentOutboxEvent(&expired)
+	if err != nil {
+		logger.LogError(log, err, "failed to build payment event")
+		return err
+	}
+	change := model.StatusChange{Status: model.PaymentExpired, Reason: "authorization expired", Version: claimed.Version}
+	// The hold is gone, the change is stored even when the expirer is shutting down
+	return s.repo.UpdateStatus(context.WithoutCancel(ctx), claimed.ID.String(), change, evt)
+}
+
+func (s *PaymentService) GetPayment(ctx context.Context, paymentID string) (*model.Payment, error) {
+	log := logger.WithCtx(ctx, "PaymentService|GetPayment")
+
+	if _, err := uuid.Parse(paymentID); err != nil {
+		return nil, invalidField("payment_id", "must be a UUID")
+	}
+
+	payment, err := s.repo.GetPayment(ctx, paymentID)
+	if err != nil {
+		logger.LogError(log, err, "failed to get payment")
+		return nil, repoError(err)
+	}
+	return payment, nil
+}
+
+func (s *PaymentService) GetPaymentByIdempotencyKey(ctx context.Context, idempotencyKey string) (*model.Payment, error) {
+	log := logger.WithCtx(ctx, "PaymentService|GetPaymentByIdempotencyKey")
+
+	if idempotencyKey == "" {
+		return nil, invalidField("idempotency_key", "is required")
+	}
+
+	payment, err := s.repo.GetPaymentByIdempotencyKey(ctx, idempotencyKey)
+	if err != nil {
+		logger.LogError(log, err, "failed to get payment by idempotency key")
+		return nil, repoError(err)
+	}
+	return payment, nil
+}
+
+func (s *PaymentService) ListPayments(ctx context.Context, filter *model.PaymentFilter, pager *paging.Pager) ([]model.Payment, error) {
+	log := logger.WithCtx(ctx, "PaymentService|ListPayments")
+
+	payments, err := s.repo.ListPayments(ctx, filter, pager)
+	if err != nil {
+		logger.LogError(log, err, "failed to list payments")
+		return nil, repoError(err)
+	}
+	return payments, nil
+}
+
+func (s *PaymentService) GetPaymentHistory(ctx context.Context, paymentID string) ([]model.PaymentStatusHistory, error) {
+	log := logger.WithCtx(ctx, "PaymentService|GetPaymentHistory")
+
+	if _, err := uuid.Parse(paymentID); err != nil {
+		return nil, invalidField("payment_id", "must be a UUID")
+	}
+
+	history, err := s.repo.GetPaymentHistory(ctx, paymentID)
+	if err != nil {
+		logger.LogError(log, err, "failed to get payment history")
+		return nil, repoError(err)
+	}
+	return history, nil
+}
+
+func moneyFromPB(m *pb.Money) (money.Money, error) {
+	if m == nil {
+		return money.Money{}, money.ErrUnknownCurrency
+	}
+	return money.New(m.Amount, m.Currency)
+}
+
+func moneyToPB(amount int64, currency string) *pb.Money {
+	return &pb.Money{Amount: amount, Currency: currency}
 }
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"payment/internal/gateway"
+	model "payment/internal/models"
+	"payment/internal/repositories"
+	"payment/pkg/core/money"
+	"payment/pkg/http/utils"
+	"payment/pkg/http/utils/app_errors"
+	pb "payment/pkg/proto/paymentpb"
+	"testing"
+	"time"
+)
+
+// fakePaymentRepo keeps payments in memory and applies status changes the way the
+// repository does, the methods a test does not need panic through the nil interface
+type fakePaymentRepo struct {
+	repositories.PaymentRepoInterface
+	payments map[string]*model.Payment
+	// captureFailures is the number of RecordCapture calls failing before one succeeds
+	captureFailures int
+}
+
+func newFakePaymentRepo(payments ...*model.Payment) *fakePaymentRepo {
+	r := &fakePaymentRepo{payments: make(map[string]*model.Payment)}
+	for _, p := range payments {
+		r.payments[p.ID.String()] = p
+	}
+	return r
+}
+
+func (r *fakePaymentRepo) GetPayment(_ context.Context, paymentID string) (*model.Payment, error) {
+	p, ok := r.payments[paymentID]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	stored := *p
+	return &stored, nil
+}
+
+func (r *fakePaymentRepo) UpdateStatus(_ context.Context, paymentID string, change model.StatusChange, _ ...*model.OutboxEvent) error {
+	p := r.payments[paymentID]
+	if change.Version != 0 && p.Version != change.Version {
+		return model.ErrConcurrentUpdate
+	}
+	if !model.CanTransition(p.Status, change.Status) {
+		return model.ErrInvalidTransition
+	}
+	p.Status = change.Status
+	p.Version++
+	p.ClaimedUntil = nil
+	if change.GatewayRef != "" {
+		p.GatewayRef = change.GatewayRef
+	}
+	if change.Error != "" {
+		p.LastError = change.Error
+		p.Attempts++
+	}
+	return nil
+}
+
+func (r *fakePaymentRepo) ClaimPayment(_ context.Context, paymentID string, version int64, lease time.Duration) (*model.Payment, error) {
+	p := r.payments[paymentID]
+	if p.Version != version || (p.ClaimedUntil != nil && p.ClaimedUntil.After(time.Now())) {
+		return nil, model.ErrConcurrentUpdate
+	}
+	p.ClaimedUntil = utils.ToPointer(time.Now().Add(lease))
+	claimed := *p
+	return &claimed, nil
+}
+
+func (r *fakePaymentRepo) ReleaseClaim(_ context.Context, paymentID string, version int64) error {
+	if p := r.payments[paymentID]; p.Version == version {
+		p.ClaimedUntil = nil
+	}
+	return nil
+}
+
+func (r *fakePaymentRepo) RecordCapture(_ context.Context, paymentID string, version int64, amount int64) (*model.Payment, error) {
+	if r.captureFailures > 0 {
+		r.captureFailures--
+		return nil, context.DeadlineExceeded
+	}
+	p := r.payments[paymentID]
+	if p.Version != version {
+		return nil, model.ErrConcurrentUpdate
+	}
+	p.CapturedAmount += amount
+	p.Status = model.PaymentPartiallyCaptured
+	if p.CapturedAmount >= p.Amount {
+		p.Status = model.PaymentCaptured
+	}
+	p.Version++
+	p.ClaimedUntil = nil
+	captured := *p
+	return &captured, nil
+}
+
+func pendingPayment(cardToken string) *model.Payment {
+	p := &model.Payment{
+		OrderID:   "o-1",
+		Amount:    1000,
+		Currency:  "USD",
+		Status:    model.PaymentPending,
+		CardToken: cardToken,
+		Version:   1,
+	}
+	p.ID = uuid.New()
+	return p
+}
+
+func TestAuthorize(t *testing.T) {
+	sim := gateway.NewSimulator(gateway.SimulatorConfig{Rules: []gateway.SimulatorRule{
+		{CardToken: "tok_decline", Outcome: gateway.OutcomeDecline, Reason: "insufficient funds"},
+		{CardToken: "tok_timeout", Outcome: gateway.OutcomeTimeout},
+	}})
+
+	tests := []struct {
+		name         string
+		cardToken    string
+		wantErr      bool
+		wantStatus   model.PaymentStatus
+		wantAttempts int
+		wantRef      bool
+	}{
+		{name: "approved", cardToken: "tok_ok", wantStatus: model.PaymentAuthorized, wantRef: true},
+		{name: "declined", cardToken: "tok_decline", wantStatus: model.PaymentDeclined, wantAttempts: 1},
+		{name: "timeout stays pending", cardToken: "tok_timeout", wantErr: true,
+			wantStatus: model.PaymentPending, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := pendingPayment(tt.cardToken)
+			repo := newFakePaymentRepo(payment)
+			s := NewPaymentService(repo, sim, 0)
+
+			claimed, _ := repo.GetPayment(context.Background(), payment.ID.String())
+			resp, err := s.authorize(context.Background(), claimed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("authorize() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && resp.Status != string(tt.wantStatus) {
+				t.Errorf("authorize() status = %s, want %s", resp.Status, tt.wantStatus)
+			}
+
+			stored := repo.payments[payment.ID.String()]
+			if stored.Status != tt.wantStatus {
+				t.Errorf("stored status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+			if stored.Attempts != tt.wantAttempts {
+				t.Errorf("stored attempts = %d, want %d", stored.Attempts, tt.wantAttempts)
+			}
+			if (stored.GatewayRef != "") != tt.wantRef {
+				t.Errorf("stored gateway ref = %q, want one %v", stored.GatewayRef, tt.wantRef)
+			}
+		})
+	}
+}
+
+// cancellingGateway approves or fails an authorization and cancels the caller meanwhile
+type cancellingGateway struct {
+	gateway.Gateway
+	cancel context.CancelFunc
+	result *gateway.Result
+	err    error
+}
+
+func (g *cancellingGateway) Authorize(context.Context, gateway.AuthorizeRequest) (*gateway.Result, error) {
+	g.cancel()
+	return g.result, g.err
+}
+
+func TestAuthorizeCancelledNeverDeclines(t *testing.T) {
+	tests := []struct {
+		name         string
+		result       *gateway.Result
+		err          error
+		wantStatus   model.PaymentStatus
+		wantAttempts int
+	}{
+		{name: "approval is stored", result: &gateway.Result{Approved: true, Reference: "ref-1"},
+			wantStatus: model.PaymentAuthorized},
+		{name: "unknown outcome stays pending", err: context.Canceled,
+			wantStatus: model.PaymentPending, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := pendingPayment("tok_ok")
+			repo := newFakePaymentRepo(payment)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			s := NewPaymentService(repo, &cancellingGateway{cancel: cancel, result: tt.result, err: tt.err}, 0)
+
+			claimed, _ := repo.GetPayment(ctx, payment.ID.String())
+			_, _ = s.authorize(ctx, claimed)
+
+			stored := repo.payments[payment.ID.String()]
+			if stored.Status != tt.wantStatus {
+				t.Errorf("stored status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+			if stored.Attempts != tt.wantAttempts {
+				t.Errorf("stored attempts = %d, want %d", stored.Attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+// voidGateway answers every void with result and err
+type voidGateway struct {
+	gateway.Gateway
+	result *gateway.Result
+	err    error
+}
+
+func (g *voidGateway) Void(context.Context, string) (*gateway.Result, error) {
+	return g.result, g.err
+}
+
+func (g *voidGateway) Capture(context.Context, string, money.Money) (*gateway.Result, error) {
+	return g.result, g.err
+}
+
+func TestExpire(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     *gateway.Result
+		err        error
+		wantErr    bool
+		wantStatus model.PaymentStatus
+		wantClaim  bool
+	}{
+		{name: "hold released", result: &gateway.Result{Approved: true}, wantStatus: model.PaymentExpired},
+		{name: "hold already gone", err: gateway.ErrUnknownReference, wantStatus: model.PaymentExpired},
+		{name: "gateway refused", result: &gateway.Result{Reason: "no"}, wantErr: true,
+			wantStatus: model.PaymentAuthorized},
+		{name: "gateway unavailable", err: errors.New("connection refused"), wantErr: true,
+			wantStatus: model.PaymentAuthorized},
+		{name: "gateway timeout keeps the claim", err: gateway.ErrTimeout, wantErr: true,
+			wantStatus: model.PaymentAuthorized, wantClaim: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := pendingPayment("tok_ok")
+			payment.Status = model.PaymentAuthorized
+			payment.GatewayRef = "sim_" + payment.ID.String()
+			repo := newFakePaymentRepo(payment)
+			s := NewPaymentService(repo, &voidGateway{result: tt.result, err: tt.err}, 0)
+
+			listed, _ := repo.GetPayment(context.Background(), payment.ID.String())
+			err := s.Expire(context.Background(), listed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expire() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			stored := repo.payments[payment.ID.String()]
+			if stored.Status != tt.wantStatus {
+				t.Errorf("stored status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+			if (stored.ClaimedUntil != nil) != tt.wantClaim {
+				t.Errorf("stored claim = %v, want one %v", stored.ClaimedUntil, tt.wantClaim)
+			}
+		})
+	}
+}
+
+func TestCapture(t *testing.T) {
+	tests := []struct {
+		name            string
+		amount          *pb.Money
+		captureFailures int
+		wantField       string
+		wantStatus      model.PaymentStatus
+		wantCaptured    int64
+	}{
+		{name: "full capture", wantStatus: model.PaymentCaptured, wantCaptured: 1000},
+		{name: "partial capture", amount: &pb.Money{Amount: 400, Currency: "USD"},
+			wantStatus: model.PaymentPartiallyCaptured, wantCaptured: 400},
+		{name: "store timeout is retried", captureFailures: 2, wantStatus: model.PaymentCaptured, wantCaptured: 1000},
+		{name: "store down", captureFailures: captureRecordAttempts, wantField: "-",
+			wantStatus: model.PaymentAuthorized},
+		{name: "unknown currency", amount: &pb.Money{Amount: 400, Currency: "XXX"}, wantField: "amount.currency",
+			wantStatus: model.PaymentAuthorized},
+		{name: "other currency", amount: &pb.Money{Amount: 400, Currency: "EUR"}, wantField: "amount.currency",
+			wantStatus: model.PaymentAuthorized},
+		{name: "negative amount", amount: &pb.Money{Amount: -1, Currency: "USD"}, wantField: "amount.amount",
+			wantStatus: model.PaymentAuthorized},
+		{name: "over the authorized amount", amount: &pb.Money{Amount: 1001, Currency: "USD"}, wantField: "amount.amount",
+			wantStatus: model.PaymentAuthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := pendingPayment("tok_ok")
+			payment.Status = model.PaymentAuthorized
+			payment.GatewayRef = "sim_" + payment.ID.String()
+			repo := newFakePaymentRepo(payment)
+			repo.captureFailures = tt.captureFailures
+			s := NewPaymentService(repo, &voidGateway{result: &gateway.Result{Approved: true}}, 0)
+
+			_, err := s.Capture(context.Background(), &pb.CaptureRequest{PaymentId: payment.ID.String(), Amount: tt.amount})
+			switch {
+			case tt.wantField == "" && err != nil:
+				t.Fatalf("Capture() error = %v", err)
+			case tt.wantField == "-" && err == nil:
+				t.Fatal("Capture() succeeded, want an error")
+			case tt.wantField != "" && tt.wantField != "-":
+				var appErr *app_errors.ResponseError
+				if !errors.As(err, &appErr) || len(appErr.ErrorResp.Violations) == 0 || appErr.ErrorResp.Violations[0].Field != tt.wantField {
+					t.Fatalf("Capture() error = %#v, want a violation of %s", err, tt.wantField)
+				}
+			}
+
+			stored := repo.payments[payment.ID.String()]
+			if stored.Status != tt.wantStatus || stored.CapturedAmount != tt.wantCaptured {
+				t.Errorf("stored = %s captured %d, want %s captured %d",
+					stored.Status, stored.CapturedAmount, tt.wantStatus, tt.wantCaptured)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"payment/internal/gateway"
+	model "payment/internal/models"
+	"payment/internal/repositories"
+	"payment/pkg/core/logger"
+	"payment/pkg/core/money"
+	"payment/pkg/http/utils/app_errors"
+	pb "payment/pkg/proto/paymentpb"
+	"time"
+)
+
+// refundClaimLease bounds how long a Refund caller owns a PENDING refund, a retry after
+// it lapses re-drives the gateway with the same refund id
+const refundClaimLease = time.Minute
+
+type RefundProcessor interface {
+	Refund(ctx context.Context, req *pb.RefundRequest) (*pb.RefundResponse, error)
+}
+
+// RefundReconciler finalizes refunds left PENDING
+type RefundReconciler interface {
+	Reconcile(ctx context.Context, refund *model.Refund) error
+}
+
+type RefundService struct {
+	repo    repositories.RefundRepoInterface
+	gateway gateway.Gateway
+}
+
+func NewRefundService(repo repositories.RefundRepoInterface, gw gateway.Gateway) *RefundService {
+	return &RefundService{repo: repo, gateway: gw}
+}
+
+func (s *RefundService) Refund(ctx context.Context, req *pb.RefundRequest) (*pb.RefundResponse, error) {
+
+	log := logger.WithTag("RefundService|Refund")
+
+	if req == nil {
+		err := app_errors.Validation()
+		logger.LogError(log, err, "nil request")
+		return nil, err
+	}
+	var violations []app_errors.FieldViolation
+	if _, err := uuid.Parse(req.PaymentId); err != nil {
+		violations = append(violations, app_errors.FieldViolation{Field: "payment_id", Description: "must be a UUID"})
+	}
+	if req.IdempotencyKey == "" {
+		violations = append(violations, app_errors.FieldViolation{Field: "idempotency_key", Description: "is required"})
+	}
+	amount, err := moneyFromPB(req.Amount)
+	if err != nil || !amount.IsPositive() {
+		violations = append(violations, app_errors.FieldViolation{Field: "amount", Description: "must be a positive amount in a known currency"})
+	}
+	if len(violations) > 0 {
+		err := app_errors.Validation(violations...)
+		logger.LogError(log, err, "invalid refund request")
+		return nil, err
+	}
+
+	refund, payment, created, err := s.repo.CreateOrGetRefund(ctx, req.PaymentId, &model.CreateRefundRequest{
+		IdempotencyKey: req.IdempotencyKey,
+		Amount:         amount.Amount,
+		Currency:       amount.Currency,
+		Reason:         req.Reason,
+	})
+	if err != nil {
+		logger.LogError(log, err, "failed to create or get refund")
+		return nil, refundRepoError(err)
+	}
+
+	// Refund đã có kết quả cuối thì trả ngay
+	if !created && refund.Status != model.RefundPending {
+		return newRefundResponse(refund, payment), nil
+	}
+
+	// Only one caller drives the gateway for a refund, the others get its stored state
+	refund, claimed, err := s.repo.ClaimRefund(ctx, refund.ID.String(), refundClaimLease)
+	if err != nil {
+		logger.LogError(log, err, "failed to claim refund")
+		return nil, refundRepoError(err)
+	}
+	if !claimed {
+		return newRefundResponse(refund, payment), nil
+	}
+
+	refund, payment, err = s.settle(ctx, refund, payment)
+	if err != nil {
+		return nil, err
+	}
+	return newRefundResponse(refund, payment), nil
+}
+
+// settle drives the gateway for a refund claimed by the caller and stores its answer.
+// When the outcome is unknown the refund stays PENDING, a retry with the same key or the
+// reconciler re-drives it once the claim lapses, the gateway refunds a refund id only once.
+func (s *RefundService) settle(ctx context.Context, refund *model.Refund, payment *model.Payment) (*model.Refund, *model.Payment, error) {
+	log := logger.WithCtx(ctx, "RefundService|settle").WithField("refund_id", refund.ID.String())
+
+	result, err := s.gateway.Refund(ctx, gateway.RefundRequest{
+		RefundID:  refund.ID.String(),
+		Reference: payment.GatewayRef,
+		Amount:    money.Money{Amount: refund.Amount, Currency: refund.Currency},
+	})
+	if err != nil {
+		logger.LogError(log, err, "gateway refund failed")
+		return nil, nil, gatewayError(err)
+	}
+
+	// The gateway answer is stored even when the caller went away meanwhile
+	ctx = context.WithoutCancel(ctx)
+	if !result.Approved {
+		if err := s.repo.FailRefund(ctx, refund.ID.String(), result.Reason); err != nil {
+			logger.LogError(log, err, "failed to mark refund as failed")
+			return nil, nil, refundRepoError(err)
+		}
+		refund.Status = model.RefundFailed
+		refund.LastError = result.Reason
+		payment.RefundedAmount -= refund.Amount
+		return refund, payment, nil
+	}
+
+	refund, payment, err = s.repo.CompleteRefund(ctx, refund.ID.String(), result.Reference)
+	if err != nil {
+		logger.LogError(log, err, "failed to complete refund")
+		return nil, nil, refundRepoError(err)
+	}
+	return refund, payment, nil
+}
+
+// Reconcile re-drives a refund left PENDING, eg. by a crash or a gateway that did not
+// answer, so its reserved amount is either refunded or released. Refunds claimed by
+// another caller are left alone.
+func (s *RefundService) Reconcile(ctx context.Context, refund *model.Refund) error {
+	claimed, ok, err := s.repo.ClaimRefund(ctx, refund.ID.String(), refundClaimLease)
+	if err != nil || !ok {
+		return err
+	}
+
+	// Shutting the worker down must not cut the attempt short, it runs to completion
+	// within the claim lease
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refundClaimLease)
+	defer cancel()
+
+	_, _, err = s.settle(ctx, claimed, refund.Payment)
+	return err
+}
+
+func newRefundResponse(refund *model.Refund, payment *model.Payment) *pb.RefundResponse {
+	return &pb.RefundResponse{
+		RefundId:       refund.ID.String(),
+		PaymentId:      payment.ID.String(),
+		Status:         string(refund.Status),
+		Amount:         moneyToPB(refund.Amount, refund.Currency),
+		RefundedAmount: moneyToPB(payment.RefundedAmount, payment.Currency),
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"payment/internal/gateway"
+	model "payment/internal/models"
+	"payment/internal/repositories"
+	"payment/pkg/http/utils/app_errors"
+	pb "payment/pkg/proto/paymentpb"
+	"testing"
+	"time"
+)
+
+// fakeRefundRepo keeps one refund in memory, the methods a test does not need panic
+// through the nil interface
+type fakeRefundRepo struct {
+	repositories.RefundRepoInterface
+	refund  *model.Refund
+	payment *model.Payment
+	claimed bool
+}
+
+func (r *fakeRefundRepo) ClaimRefund(context.Context, string, time.Duration) (*model.Refund, bool, error) {
+	if r.claimed || r.refund.Status != model.RefundPending {
+		return r.refund, false, nil
+	}
+	r.claimed = true
+	stored := *r.refund
+	return &stored, true, nil
+}
+
+func (r *fakeRefundRepo) CompleteRefund(_ context.Context, _ string, gatewayRef string) (*model.Refund, *model.Payment, error) {
+	r.refund.Status = model.RefundSucceeded
+	r.refund.GatewayRef = gatewayRef
+	r.claimed = false
+	return r.refund, r.payment, nil
+}
+
+func (r *fakeRefundRepo) FailRefund(_ context.Context, _ string, reason string) error {
+	r.refund.Status = model.RefundFailed
+	r.refund.LastError = reason
+	r.payment.RefundedAmount -= r.refund.Amount
+	r.claimed = false
+	return nil
+}
+
+// refundGateway answers every refund with result and err
+type refundGateway struct {
+	gateway.Gateway
+	result *gateway.Result
+	err    error
+}
+
+func (g *refundGateway) Refund(context.Context, gateway.RefundRequest) (*gateway.Result, error) {
+	return g.result, g.err
+}
+
+func TestRefundValidatesPaymentID(t *testing.T) {
+	tests := []struct {
+		name      string
+		paymentID string
+	}{
+		{name: "missing", paymentID: ""},
+		{name: "not a uuid", paymentID: "p-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRefundService(&fakeRefundRepo{}, &refundGateway{})
+			_, err := s.Refund(context.Background(), &pb.RefundRequest{
+				PaymentId:      tt.paymentID,
+				IdempotencyKey: "k-1",
+				Amount:         &pb.Money{Amount: 100, Currency: "USD"},
+			})
+			var appErr *app_errors.ResponseError
+			if !errors.As(err, &appErr) || len(appErr.ErrorResp.Violations) != 1 || appErr.ErrorResp.Violations[0].Field != "payment_id" {
+				t.Fatalf("Refund() error = %#v, want a payment_id violation", err)
+			}
+		})
+	}
+}
+
+func TestRefundReconcile(t *testing.T) {
+	tests := []struct {
+		name         string
+		result       *gateway.Result
+		err          error
+		claimed      bool
+		wantErr      bool
+		wantStatus   model.RefundStatus
+		wantReserved int64
+	}{
+		{name: "refunded", result: &gateway.Result{Approved: true, Reference: "ref-1"},
+			wantStatus: model.RefundSucceeded, wantReserved: 300},
+		{name: "declined releases the reservation", result: &gateway.Result{Reason: "too late"},
+			wantStatus: model.RefundFailed},
+		{name: "gateway timeout stays pending", err: gateway.ErrTimeout, wantErr: true,
+			wantStatus: model.RefundPending, wantReserved: 300},
+		{name: "claimed by another caller", claimed: true, result: &gateway.Result{Approved: true},
+			wantStatus: model.RefundPending, wantReserved: 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := &model.Payment{Amount: 1000, Currency: "USD", CapturedAmount: 1000, RefundedAmount: 300, GatewayRef: "sim_p-1"}
+			payment.ID = uuid.New()
+			refund := &model.Refund{PaymentID: payment.ID, Amount: 300, Currency: "USD", Status: model.RefundPending}
+			refund.ID = uuid.New()
+			repo := &fakeRefundRepo{refund: refund, payment: payment, claimed: tt.claimed}
+			s := NewRefundService(repo, &refundGateway{result: tt.result, err: tt.err})
+
+			listed, listedPayment := *refund, *payment
+			listed.Payment = &listedPayment
+			err := s.Reconcile(context.Background(), &listed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reconcile() error = %v, want error %v", err, tt.wantErr)
+			}
+			if refund.Status != tt.wantStatus {
+				t.Errorf("stored status = %s, want %s", refund.Status, tt.wantStatus)
+			}
+			if payment.RefundedAmount != tt.wantReserved {
+				t.Errorf("refunded amount = %d, want %d", payment.RefundedAmount, tt.wantReserved)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/google/uuid"
+	model "payment/internal/models"
+	"payment/internal/repositories"
+	"payment/pkg/core/logger"
+	"payment/pkg/core/webhook"
+	"payment/pkg/http/paging"
+	"payment/pkg/http/utils/app_errors"
+	"strings"
+)
+
+// minWebhookSecretLength keeps merchant supplied secrets hard enough to guess
+const minWebhookSecretLength = 16
+
+type WebhookManager interface {
+	RegisterEndpoint(ctx context.Context, req *model.CreateWebhookEndpointRequest) (*model.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, merchantID string, pager *paging.Pager) ([]model.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, merchantID, id string) error
+	ListDeliveries(ctx context.Context, merchantID, endpointID string, pager *paging.Pager) ([]model.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, merchantID, endpointID, deliveryID string) (*model.WebhookDelivery, error)
+}
+
+type WebhookService struct {
+	repo repositories.WebhookRepoInterface
+}
+
+func NewWebhookService(repo repositories.WebhookRepoInterface) *WebhookService {
+	return &WebhookService{repo: repo}
+}
+
+// RegisterEndpoint stores a merchant endpoint, generating its signing secret when none is given
+func (s *WebhookService) RegisterEndpoint(ctx context.Context, req *model.CreateWebhookEndpointRequest) (*model.WebhookEndpoint, error) {
+	log := logger.WithCtx(ctx, "WebhookService|RegisterEndpoint")
+
+	var violations []app_errors.FieldViolation
+	if strings.TrimSpace(req.MerchantID) == "" {
+		violations = append(violations, app_errors.FieldViolation{Field: "merchant_id", Description: "is required"})
+	}
+	if err := webhook.CheckURL(ctx, req.URL); err != nil {
+		violations = append(violations, app_errors.FieldViolation{Field: "url", Description: err.Error()})
+	}
+	if req.Secret != "" && len(req.Secret) < minWebhookSecretLength {
+		violations = append(violations, app_errors.FieldViolation{Field: "secret", Description: "must be at least 16 characters"})
+	}
+	for _, t := range req.EventTypes {
+		if !strings.HasPrefix(t, "payment.") {
+			violations = append(violations, app_errors.FieldViolation{Field: "event_types", Description: "unknown event type " + t})
+		}
+	}
+	if len(violations) > 0 {
+		err := app_errors.Validation(violations...)
+		logger.LogError(log, err, "invalid webhook endpoint")
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = newWebhookSecret(); err != nil {
+			logger.LogError(log, err, "failed to generate webhook secret")
+			return nil, app_errors.Internal()
+		}
+	}
+
+	endpoint := &model.WebhookEndpoint{
+		MerchantID: strings.TrimSpace(req.MerchantID),
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: req.EventTypes,
+	}
+	if err := s.repo.CreateEndpoint(ctx, endpoint); err != nil {
+		logger.LogError(log, err, "failed to create webhook endpoint")
+		return nil, storeError(err)
+	}
+	return endpoint, nil
+}
+
+// ListEndpoints returns the endpoints of one merchant, merchantID is required
+func (s *WebhookService) ListEndpoints(ctx context.Context, merchantID string, pager *paging.Pager) ([]model.WebhookEndpoint, error) {
+	if strings.TrimSpace(merchantID) == "" {
+		return nil, invalidField("merchant_id", "is required")
+	}
+	endpoints, err := s.repo.ListEndpoints(ctx, strings.TrimSpace(merchantID), pager)
+	if err != nil {
+		logger.LogError(logger.WithCtx(ctx, "WebhookService|ListEndpoints"), err, "failed to list webhook endpoints")
+		return nil, storeError(err)
+	}
+	return endpoints, nil
+}
+
+func (s *WebhookService) DeleteEndpoint(ctx context.Context, merchantID, id string) error {
+	if err := validateEndpointRef(merchantID, id); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteEndpoint(ctx, strings.TrimSpace(merchantID), id); err != nil {
+		logger.LogError(logger.WithCtx(ctx, "WebhookService|DeleteEndpoint"), err, "failed to delete webhook endpoint")
+		return webhookRepoError(err, ReasonWebhookNotFound)
+	}
+	return nil
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, merchantID, endpointID string, pager *paging.Pager) ([]model.WebhookDelivery, error) {
+	if err := validateEndpointRef(merchantID, endpointID); err != nil {
+		return nil, err
+	}
+	if err := s.checkOwner(ctx, merchantID, endpointID); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.repo.ListDeliveries(ctx, endpointID, pager)
+	if err != nil {
+		logger.LogError(logger.WithCtx(ctx, "WebhookService|ListDeliveries"), err, "failed to list webhook deliveries")
+		return nil, storeError(err)
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery sends a delivery again, eg. after the merchant fixed their endpoint
+func (s *WebhookService) ReplayDelivery(ctx context.Context, merchantID, endpointID, deliveryID string) (*model.WebhookDelivery, error) {
+	var violations []app_errors.FieldViolation
+	if strings.TrimSpace(merchantID) == "" {
+		violations = append(violations, app_errors.FieldViolation{Field: "merchant_id", Description: "is required"})
+	}
+	if _, err := uuid.Parse(endpointID); err != nil {
+		violations = append(violations, app_errors.FieldViolation{Field: "id", Description: "must be a uuid"})
+	}
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		violations = append(violations, app_errors.FieldViolation{Field: "delivery_id", Description: "must be a uuid"})
+	}
+	if len(violations) > 0 {
+		return nil, app_errors.Validation(violations...)
+	}
+	if err := s.checkOwner(ctx, merchantID, endpointID); err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.repo.ReplayDelivery(ctx, endpointID, deliveryID)
+	if err != nil {
+		logger.LogError(logger.WithCtx(ctx, "WebhookService|ReplayDelivery"), err, "failed to replay webhook delivery")
+		return nil, webhookRepoError(err, ReasonDeliveryNotFound)
+	}
+	return delivery, nil
+}
+
+// validateEndpointRef checks the merchant and endpoint a request addresses
+func validateEndpointRef(merchantID, endpointID string) error {
+	var violations []app_errors.FieldViolation
+	if strings.TrimSpace(merchantID) == "" {
+		violations = append(violations, app_errors.FieldViolation{Field: "merchant_id", Description: "is required"})
+	}
+	if _, err := uuid.Parse(endpointID); err != nil {
+		violations = append(violations, app_errors.FieldViolation{Field: "id", Description: "must be a uuid"})
+	}
+	if len(violations) > 0 {
+		return app_errors.Validation(violations...)
+	}
+	return nil
+}
+
+// checkOwner reports an endpoint of another merchant as not found, so its existence is not leaked
+func (s *WebhookService) checkOwner(ctx context.Context, merchantID, endpointID string) error {
+	endpoint, err := s.repo.GetEndpoint(ctx, endpointID)
+	if err != nil {
+		return webhookRepoError(err, ReasonWebhookNotFound)
+	}
+	if endpoint.MerchantID != strings.TrimSpace(merchantID) {
+		return app_errors.NotFound(ReasonWebhookNotFound)
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,84 @@
+package workers
+
+import (
+	"context"
+	"payment/internal/repositories"
+	"payment/internal/services"
+	"payment/pkg/core/logger"
+	"payment/pkg/http/utils"
+	"time"
+)
+
+// expirerActor is recorded in the payment status history for changes made by the expirer
+const expirerActor = "worker:expirer"
+
+type AuthorizationExpirerConfig struct {
+	PollInterval time.Duration
+	// TTL is how long an authorization can be captured, it should not exceed the hold
+	// the gateway places on the funds
+	TTL       time.Duration
+	BatchSize int
+}
+
+// AuthorizationExpirer releases the gateway hold of AUTHORIZED payments that were not
+// captured within TTL and moves them to EXPIRED, emitting their event through the outbox
+type AuthorizationExpirer struct {
+	repo repositories.PaymentRepoInterface
+	svc  services.PaymentExpirer
+	cfg  AuthorizationExpirerConfig
+}
+
+func NewAuthorizationExpirer(repo repositories.PaymentRepoInterface, svc services.PaymentExpirer, cfg AuthorizationExpirerConfig) *AuthorizationExpirer {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Minute
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 7 * 24 * time.Hour
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &AuthorizationExpirer{repo: repo, svc: svc, cfg: cfg}
+}
+
+// Run expires lapsed authorizations until ctx is cancelled
+func (e *AuthorizationExpirer) Run(ctx context.Context) {
+	log := logger.WithTag("AuthorizationExpirer|Run")
+	ctx = utils.WithActor(ctx, expirerActor)
+
+	ticker := time.NewTicker(e.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		e.expireBatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Infof("authorization expirer stopped: %v", ctx.Err())
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *AuthorizationExpirer) expireBatch(ctx context.Context) {
+	log := logger.WithTag("AuthorizationExpirer|expireBatch")
+
+	payments, err := e.repo.ListExpiredAuthorizations(ctx, time.Now().Add(-e.cfg.TTL), e.cfg.BatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.LogError(log, err, "failed to list expired authorizations")
+		}
+		return
+	}
+
+	for i := range payments {
+		if ctx.Err() != nil {
+			return
+		}
+		p := &payments[i]
+		if err := e.svc.Expire(ctx, p); err != nil {
+			logger.LogError(log.WithField("payment_id", p.ID.String()), err, "failed to expire authorization")
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	model "payment/internal/models"
+	"payment/internal/repositories"
+	"payment/pkg/core/backoff"
+	"payment/pkg/core/kafka/payment"
+	"payment/pkg/core/logger"
+	"payment/pkg/core/tracing"
+	"payment/pkg/http/utils"
+	"time"
+)
+
+type OutboxRelayConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// Lease is how long a claimed batch is hidden from other relays, it must cover
+	// publishing the whole batch
+	Lease time.Duration
+}
+
+// OutboxRelay drains outbox_events into kafka. Failed sends are retried with
+// exponential backoff until MaxAttempts, then the event is marked FAILED.
+type OutboxRelay struct {
+	repo     repositories.OutboxRepoInterface
+	producer *payment.Producer
+	cfg      OutboxRelayConfig
+}
+
+func NewOutboxRelay(repo repositories.OutboxRepoInterface, producer *payment.Producer, cfg OutboxRelayConfig) *OutboxRelay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.BaseBackoff {
+		cfg.MaxBackoff = cfg.BaseBackoff
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 2 * time.Minute
+	}
+	return &OutboxRelay{repo: repo, producer: producer, cfg: cfg}
+}
+
+// Run polls the outbox until ctx is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	log := logger.WithTag("OutboxRelay|Run")
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep draining while full batches come back
+		for {
+			events, err := r.repo.ClaimDue(ctx, r.cfg.BatchSize, r.cfg.Lease)
+			if err != nil && ctx.Err() == nil {
+				logger.LogError(log, err, "failed to claim outbox events")
+			}
+			r.deliverBatch(ctx, events)
+			if err != nil || len(events) < r.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Infof("outbox relay stopped: %v", ctx.Err())
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverBatch publishes claimed events one by one and records every outcome on its own.
+// Events left once the lease ran out are not published, another relay may have claimed them.
+func (r *OutboxRelay) deliverBatch(ctx context.Context, events []model.OutboxEvent) {
+	log := logger.WithTag("OutboxRelay|deliverBatch")
+
+	deadline := time.Now().Add(r.cfg.Lease)
+	for i := range events {
+		if ctx.Err() != nil || time.Now().After(deadline) {
+			// The lease expires and the events are claimed again
+			return
+		}
+		evt := &events[i]
+		r.deliver(ctx, evt)
+		if ctx.Err() != nil {
+			// Shutting down mid publish, the outcome is left to the next claim
+			return
+		}
+		if err := r.repo.RecordDelivery(context.WithoutCancel(ctx), evt); err != nil {
+			logger.LogError(log.WithField("outbox_id", evt.ID), err, "failed to record outbox delivery")
+		}
+	}
+}
+
+func (r *OutboxRelay) deliver(ctx context.Context, evt *model.OutboxEvent) {
+	// Publish within the trace of the request that wrote the event
+	err := r.producer.Publish(tracing.WithTraceParent(ctx, evt.TraceParent), evt.AggregateKey, newEnvelope(evt))
+	if err == nil {
+		now := time.Now()
+		evt.Status = model.OutboxDelivered
+		evt.DeliveredAt = &now
+		evt.LastError = ""
+		return
+	}
+
+	evt.Attempts++
+	evt.LastError = err.Error()
+	if evt.Attempts >= r.cfg.MaxAttempts {
+		evt.Status = model.OutboxFailed
+		logger.LogError(logger.WithTag("OutboxRelay|deliver").WithField("outbox_id", evt.ID), err, "outbox event delivery failed permanently")
+		return
+	}
+	evt.NextAttemptAt = time.Now().Add(backoff.Exponential(r.cfg.BaseBackoff, r.cfg.MaxBackoff, evt.Attempts))
+}
+
+// newEnvelope wraps an outbox event, its id and time are fixed when the event is written
+// so every delivery attempt carries the same envelope
+func newEnvelope(evt *model.OutboxEvent) *payment.Envelope {
+	return &payment.Envelope{
+		ID:            evt.EventID.String(),
+		Type:          evt.EventType,
+		Source:        utils.APPNAME,
+		Time:          evt.CreatedAt,
+		SchemaVersion: evt.SchemaVersion,
+		TraceID:       evt.TraceID,
+		Data:          json.RawMessage(evt.Payload),
+	}
+}
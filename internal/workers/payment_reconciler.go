@@ -0,0 +1,102 @@
+package workers
+
+import (
+	"context"
+	"payment/internal/repositories"
+	"payment/internal/services"
+	"payment/pkg/core/logger"
+	"payment/pkg/http/utils"
+	"time"
+)
+
+// reconcilerActor is recorded in the payment status history for changes made by the reconciler
+const reconcilerActor = "worker:reconciler"
+
+type PaymentReconcilerConfig struct {
+	PollInterval time.Duration
+	// StuckAfter is how long a payment must stay PENDING before it is reconciled,
+	// it must exceed the time a Pay call may hold the payment
+	StuckAfter  time.Duration
+	BatchSize   int
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// PaymentReconciler finalizes payments left PENDING by a crash or a cancelled call.
+// Authorization is retried with exponential backoff until MaxAttempts, then the
+// payment is declined. Every final transition emits its event through the outbox.
+type PaymentReconciler struct {
+	repo repositories.PaymentRepoInterface
+	svc  services.PaymentReconciler
+	cfg  PaymentReconcilerConfig
+}
+
+func NewPaymentReconciler(repo repositories.PaymentRepoInterface, svc services.PaymentReconciler, cfg PaymentReconcilerConfig) *PaymentReconciler {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 30 * time.Second
+	}
+	if cfg.StuckAfter <= 0 {
+		cfg.StuckAfter = 5 * time.Minute
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = 30 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.BaseBackoff {
+		cfg.MaxBackoff = cfg.BaseBackoff
+	}
+	return &PaymentReconciler{repo: repo, svc: svc, cfg: cfg}
+}
+
+// Run reconciles stuck payments until ctx is cancelled
+func (r *PaymentReconciler) Run(ctx context.Context) {
+	log := logger.WithTag("PaymentReconciler|Run")
+	ctx = utils.WithActor(ctx, reconcilerActor)
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.reconcileBatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Infof("payment reconciler stopped: %v", ctx.Err())
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *PaymentReconciler) reconcileBatch(ctx context.Context) {
+	log := logger.WithTag("PaymentReconciler|reconcileBatch")
+
+	payments, err := r.repo.ListStuckPending(ctx, repositories.StuckPaymentQuery{
+		StuckAfter:  r.cfg.StuckAfter,
+		BaseBackoff: r.cfg.BaseBackoff,
+		MaxBackoff:  r.cfg.MaxBackoff,
+		Limit:       r.cfg.BatchSize,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.LogError(log, err, "failed to list stuck payments")
+		}
+		return
+	}
+
+	for i := range payments {
+		if ctx.Err() != nil {
+			return
+		}
+		p := &payments[i]
+		if err := r.svc.Reconcile(ctx, p, r.cfg.MaxAttempts); err != nil {
+			logger.LogError(log.WithField("payment_id", p.ID.String()).WithField("attempts", p.Attempts), err, "failed to reconcile payment")
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package workers
+
+import (
+	"context"
+	"payment/internal/repositories"
+	"payment/internal/services"
+	"payment/pkg/core/logger"
+	"time"
+)
+
+type RefundReconcilerConfig struct {
+	PollInterval time.Duration
+	// StuckAfter is how long a refund must stay PENDING before it is reconciled, it must
+	// exceed the time a Refund call may hold the refund
+	StuckAfter time.Duration
+	BatchSize  int
+}
+
+// RefundReconciler finalizes refunds left PENDING by a crash or a gateway that did not
+// answer. The refund is re-driven with its id as the gateway idempotency reference until
+// the gateway answers, then its reservation is either refunded or released.
+type RefundReconciler struct {
+	repo repositories.RefundRepoInterface
+	svc  services.RefundReconciler
+	cfg  RefundReconcilerConfig
+}
+
+func NewRefundReconciler(repo repositories.RefundRepoInterface, svc services.RefundReconciler, cfg RefundReconcilerConfig) *RefundReconciler {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 30 * time.Second
+	}
+	if cfg.StuckAfter <= 0 {
+		cfg.StuckAfter = 5 * time.Minute
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	return &RefundReconciler{repo: repo, svc: svc, cfg: cfg}
+}
+
+// Run reconciles stuck refunds until ctx is cancelled
+func (r *RefundReconciler) Run(ctx context.Context) {
+	log := logger.WithTag("RefundReconciler|Run")
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.reconcileBatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Infof("refund reconciler stopped: %v", ctx.Err())
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *RefundReconciler) reconcileBatch(ctx context.Context) {
+	log := logger.WithTag("RefundReconciler|reconcileBatch")
+
+	refunds, err := r.repo.ListStuckRefunds(ctx, r.cfg.StuckAfter, r.cfg.BatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.LogError(log, err, "failed to list stuck refunds")
+		}
+		return
+	}
+
+	for i := range refunds {
+		if ctx.Err() != nil {
+			return
+		}
+		refund := &refunds[i]
+		if err := r.svc.Reconcile(ctx, refund); err != nil {
+			logger.LogError(log.WithField("refund_id", refund.ID.String()), err, "failed to reconcile refund")
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	model "payment/internal/models"
+	"payment/internal/repositories"
+	"payment/pkg/core/backoff"
+	"payment/pkg/core/kafka/payment"
+	"payment/pkg/core/logger"
+	"payment/pkg/core/webhook"
+	"payment/pkg/http/utils"
+	"time"
+)
+
+type WebhookDispatcherConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// Timeout bounds a single request to an endpoint
+	Timeout time.Duration
+}
+
+// WebhookDispatcher posts pending webhook deliveries to merchant endpoints. Every
+// request is signed with the endpoint secret and logged; failures are retried with
+// exponential backoff until MaxAttempts, then the delivery is marked FAILED and can
+// only be sent again through a replay. Deliveries are not ordered, receivers should
+// dedupe on the event id and compare event times.
+type WebhookDispatcher struct {
+	repo   repositories.WebhookRepoInterface
+	client *http.Client
+	cfg    WebhookDispatcherConfig
+}
+
+func NewWebhookDispatcher(repo repositories.WebhookRepoInterface, cfg WebhookDispatcherConfig) *WebhookDispatcher {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.BaseBackoff {
+		cfg.MaxBackoff = cfg.BaseBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &WebhookDispatcher{
+		repo: repo,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// No proxy and a dialer refusing internal addresses, endpoints are merchant input
+			Transport: &http.Transport{
+				DialContext:         webhook.NewDialer(cfg.Timeout).DialContext,
+				TLSHandshakeTimeout: cfg.Timeout,
+				MaxIdleConnsPerHost: 2,
+			},
+		},
+		cfg: cfg,
+	}
+}
+
+// Run polls for due deliveries until ctx is cancelled
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	log := logger.WithTag("WebhookDispatcher|Run")
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			// Lease covers the slowest batch so a claimed delivery is not sent twice
+			deliveries, err := d.repo.ClaimDue(ctx, d.cfg.BatchSize, time.Duration(d.cfg.BatchSize+1)*d.cfg.Timeout)
+			if err != nil && ctx.Err() == nil {
+				logger.LogError(log, err, "failed to claim webhook deliveries")
+			}
+			for i := range deliveries {
+				d.deliver(ctx, &deliveries[i])
+			}
+			if err != nil || len(deliveries) < d.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Infof("webhook dispatcher stopped: %v", ctx.Err())
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *model.WebhookDelivery) {
+	log := logger.WithTag("WebhookDispatcher|deliver").WithField("delivery_id", delivery.ID)
+
+	start := time.Now()
+	statusCode, err := d.send(ctx, delivery)
+	if ctx.Err() != nil {
+		// Shutting down, the lease expires and the delivery is picked up again
+		return
+	}
+
+	delivery.Attempts++
+	attempt := &model.WebhookDeliveryAttempt{
+		DeliveryID: delivery.ID,
+		Attempt:    delivery.Attempts,
+		StatusCode: statusCode,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err == nil {
+		now := time.Now()
+		delivery.Status = model.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	} else {
+		attempt.Error = err.Error()
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= d.cfg.MaxAttempts || delivery.Endpoint == nil {
+			delivery.Status = model.WebhookDeliveryFailed
+			logger.LogError(log, err, "webhook delivery failed permanently")
+		} else {
+			delivery.NextAttemptAt = time.Now().Add(backoff.Exponential(d.cfg.BaseBackoff, d.cfg.MaxBackoff, delivery.Attempts))
+		}
+	}
+
+	if err := d.repo.RecordAttempt(ctx, delivery, attempt); err != nil {
+		logger.LogError(log, err, "failed to record webhook delivery attempt")
+	}
+}
+
+// send posts the delivery to its endpoint and returns the response status code
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *model.WebhookDelivery) (int, error) {
+	if delivery.Endpoint == nil {
+		return 0, errors.New("webhook endpoint was deleted")
+	}
+
+	body, err := json.Marshal(&payment.Envelope{
+		ID:              delivery.EventID.String(),
+		Type:            delivery.EventType,
+		Source:          utils.APPNAME,
+		Time:            delivery.EventTime,
+		SpecVersion:     payment.SpecVersion,
+		SchemaVersion:   delivery.SchemaVersion,
+		TraceID:         delivery.TraceID,
+		DataContentType: "application/json",
+		Data:            json.RawMessage(delivery.Payload),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.HeaderID, delivery.ID.String())
+	req.Header.Set(webhook.HeaderEvent, delivery.EventType)
+	req.Header.Set(webhook.HeaderTimestamp, fmt.Sprintf("%d", timestamp))
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(delivery.Endpoint.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
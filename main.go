@@ -9,69 +9,86 @@ import (
 	"os/signal"
 	"payment/internal/bootstrap"
 	"payment/internal/http/routes"
+	"payment/pkg/core/configloader"
 	"payment/pkg/core/logger"
 	"payment/pkg/http/middlewares"
 	"payment/pkg/http/utils"
 	"syscall"
-	"time"
 )
 
 func main() {
 	logger.Init(utils.APPNAME)
 	logger.SetupLogger()
 
+	// Fail fast on a bad config, the report lists every invalid setting
+	cfg, err := configloader.Load()
+	if err != nil {
+		logger.LogError(logger.WithTag("Backend|Main"), err, "failed to load configuration")
+		os.Exit(1)
+	}
+
 	// Initialize application
-	app, err := bootstrap.InitializeApp()
+	app, err := bootstrap.InitializeApp(cfg)
 	if err != nil {
 		logger.LogError(logger.WithTag("Backend|Main"), err, "failed to initialize application")
 		return
 	}
 
-	kafkaApp, stopKafka := bootstrap.InitKafka(context.Background())
-	defer stopKafka()
-
-	// Setup and start server
-	router := gin.Default()
-	router.Use(limit.MaxAllowed(200))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	configCors, err := middlewares.ConfigCors()
-	if err != nil {
-		logger.LogError(logger.WithTag("Backend|Main"), err, "failed to initialize cors")
+	// Schema changes run as a one-off subcommand, eg. `payment migrate up`
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := bootstrap.RunMigrate(ctx, app, os.Args[2:], os.Stdout); err != nil {
+			logger.LogError(logger.WithTag("Backend|Migrate"), err, "migration failed")
+			stop()
+			os.Exit(1)
+		}
 		return
 	}
 
-	routes.NewHTTPServer(router, configCors, app)
-
-	httpSrv, httpErrCh := bootstrap.StartServer(router, app.Config)
+	bootstrap.InitServices(app)
+	bootstrap.InitHealth(app)
 
-	go func() {
-		if err := <-httpErrCh; err != nil {
-			log.Fatalf("http server error: %v", err)
-		}
-	}()
-
-	grpcSrv, err := bootstrap.StartGRPC(app, *kafkaApp)
+	kafkaComponent, err := bootstrap.NewKafka(app)
 	if err != nil {
-		log.Fatalf("failed to start grpc: %v", err)
+		logger.LogError(logger.WithTag("Backend|Main"), err, "failed to initialize kafka")
+		stop()
+		os.Exit(1)
 	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
-	<-ctx.Done()
-
-	log.Println("shutting down...")
+	// Setup server
+	router := gin.Default()
+	router.Use(limit.MaxAllowed(200))
 
-	// Stop gRPC gracefully (safe nil-check)
-	if grpcSrv != nil {
-		grpcSrv.Stop()
+	configCors, err := middlewares.ConfigCors()
+	if err != nil {
+		logger.LogError(logger.WithTag("Backend|Main"), err, "failed to initialize cors")
+		stop()
+		os.Exit(1)
 	}
 
-	// Shutdown HTTP server with timeout
-	if httpSrv != nil {
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := httpSrv.Shutdown(shutdownCtx); err != nil {
-			log.Printf("http shutdown error: %v", err)
-		}
+	routes.NewHTTPServer(router, configCors, app)
+
+	// Started in this order and stopped in reverse: the servers drain in-flight payments
+	// before the workers stop, and the producer is flushed once nothing publishes anymore
+	lifecycle := bootstrap.NewLifecycle(app.Config.ShutdownTimeout)
+	lifecycle.Add(
+		bootstrap.NewTracing(app),
+		kafkaComponent,
+		bootstrap.NewOutboxRelay(app, kafkaComponent.Producer),
+		bootstrap.NewPaymentReconciler(app),
+		bootstrap.NewRefundReconciler(app),
+		bootstrap.NewAuthorizationExpirer(app),
+		bootstrap.NewWebhookDispatcher(app),
+		bootstrap.NewHTTPServer(router, app.Config),
+		bootstrap.NewGRPC(app),
+		bootstrap.NewHealthChecker(app),
+	)
+	if err := lifecycle.Run(ctx); err != nil {
+		logger.LogError(logger.WithTag("Backend|Main"), err, "service stopped with errors")
+		stop()
+		os.Exit(1)
 	}
+	log.Println("service stopped")
 }
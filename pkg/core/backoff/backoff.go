@@ -0,0 +1,22 @@
+package backoff
+
+import "time"
+
+// Exponential returns the wait after attempts failed attempts: base doubled for every
+// attempt after the first, capped at max. A max below base caps at base.
+func Exponential(base, max time.Duration, attempts int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	if max < base {
+		max = base
+	}
+	d := base
+	for i := 1; i < attempts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
@@ -0,0 +1,31 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponential(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     time.Duration
+		max      time.Duration
+		attempts int
+		want     time.Duration
+	}{
+		{name: "first attempt", base: time.Second, max: time.Minute, attempts: 1, want: time.Second},
+		{name: "no attempt yet", base: time.Second, max: time.Minute, attempts: 0, want: time.Second},
+		{name: "doubles", base: time.Second, max: time.Minute, attempts: 3, want: 4 * time.Second},
+		{name: "capped", base: time.Second, max: 10 * time.Second, attempts: 5, want: 10 * time.Second},
+		{name: "many attempts stay capped", base: time.Second, max: time.Minute, attempts: 1000, want: time.Minute},
+		{name: "max below base", base: time.Minute, max: time.Second, attempts: 4, want: time.Minute},
+		{name: "no base", base: 0, max: time.Minute, attempts: 3, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Exponential(tt.base, tt.max, tt.attempts); got != tt.want {
+				t.Errorf("Exponential(%s, %s, %d) = %s, want %s", tt.base, tt.max, tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
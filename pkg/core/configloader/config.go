@@ -1,52 +1,111 @@
 package configloader
 
 import (
-	"fmt"
-	"github.com/caarlos0/env/v6"
-	"github.com/joho/godotenv"
-	"sync"
+	"time"
 )
 
+// Config is the whole service configuration, see Load for where values come from. It is
+// built once in main and handed down, nothing reads it globally.
 type Config struct {
 	// Database configs
 	PostgresUser     string `env:"POSTGRES_USER"`
 	PostgresPassword string `env:"POSTGRES_PASSWORD"`
 	PostgresHost     string `env:"POSTGRES_HOST"`
-	PostgresPort     string `env:"POSTGRES_PORT" envDefault:"5432"`
+	PostgresPort     int    `env:"POSTGRES_PORT" envDefault:"5432"`
 	PostgresDatabase string `env:"POSTGRES_DATABASE"`
 
 	// Server configs
-	ServerPort string `env:"SERVER_PORT" envDefault:"8080"`
+	ServerPort int `env:"SERVER_PORT" envDefault:"8080"`
 
 	// JWT Security configs
-	JWTAccessSecure     string `env:"JWT_ACCESS_SECURE"`
-	JWTRefreshSecure    string `env:"JWT_REFRESH_SECURE"`
-	JWTAccessTimeMinute string `env:"JWT_ACCESS_TIME_MINUTE" envDefault:"15"`
-	JWTRefreshTimeHour  string `env:"JWT_REFRESH_TIME_HOUR" envDefault:"168"`
+	JWTAccessSecure  string        `env:"JWT_ACCESS_SECURE"`
+	JWTRefreshSecure string        `env:"JWT_REFRESH_SECURE"`
+	JWTAccessTTL     time.Duration `env:"JWT_ACCESS_TTL" envDefault:"15m"`
+	JWTRefreshTTL    time.Duration `env:"JWT_REFRESH_TTL" envDefault:"168h"`
 
-	KafkaBrokers                []string `env:"KAFKA_BROKERS"`
-	KafkaTopicPaymentAuthorized string   `env:"KAFKA_PAYMENT_AUTHORIZED_TOPIC"`
+	KafkaBrokers                []string      `env:"KAFKA_BROKERS"`
+	KafkaTopicPaymentAuthorized string        `env:"KAFKA_PAYMENT_AUTHORIZED_TOPIC" envDefault:"payment_authorized"`
+	KafkaTopicOrder             string        `env:"KAFKA_ORDER_CREATED_TOPIC" envDefault:"order_created"`
+	KafkaOrderGroupID           string        `env:"KAFKA_ORDER_GROUP_ID" envDefault:"order_group"`
+	KafkaTopicOrderDeadLetter   string        `env:"KAFKA_ORDER_DLQ_TOPIC" envDefault:"order_created.dlq"`
+	KafkaConsumerMaxRetries     int           `env:"KAFKA_CONSUMER_MAX_RETRIES" envDefault:"5"`
+	KafkaConsumerRetryBackoff   time.Duration `env:"KAFKA_CONSUMER_RETRY_BACKOFF" envDefault:"500ms"`
+	KafkaConsumerMaxBackoff     time.Duration `env:"KAFKA_CONSUMER_MAX_BACKOFF" envDefault:"30s"`
+	// KafkaEventTopics routes event types to topics, eg. payment.refunded:payment_refunded,
+	// types not listed go to KafkaTopicPaymentAuthorized
+	KafkaEventTopics map[string]string `env:"KAFKA_EVENT_TOPICS"`
+	// KafkaEventEncoding is json or protobuf, advertised in the content-type header of every event
+	KafkaEventEncoding string `env:"KAFKA_EVENT_ENCODING" envDefault:"json"`
+
+	// Kafka producer delivery configs, acks is none, one or all and compression is
+	// none, gzip, snappy, lz4 or zstd. Async is rejected by Validate, the outbox relay
+	// may only mark an event delivered once kafka acknowledged it.
+	KafkaProducerRequiredAcks string        `env:"KAFKA_PRODUCER_REQUIRED_ACKS" envDefault:"all"`
+	KafkaProducerMaxAttempts  int           `env:"KAFKA_PRODUCER_MAX_ATTEMPTS" envDefault:"10"`
+	KafkaProducerBatchSize    int           `env:"KAFKA_PRODUCER_BATCH_SIZE" envDefault:"100"`
+	KafkaProducerBatchTimeout time.Duration `env:"KAFKA_PRODUCER_BATCH_TIMEOUT" envDefault:"10ms"`
+	KafkaProducerCompression  string        `env:"KAFKA_PRODUCER_COMPRESSION" envDefault:"none"`
+	KafkaProducerAsync        bool          `env:"KAFKA_PRODUCER_ASYNC" envDefault:"false"`
 
 	// gRPC and HTTP ports
-	GRPCPort string `env:"GRPC_PORT" envDefault:"50052"`
-	HTTPPort string `env:"HTTP_PORT" envDefault:"8081"`
-}
+	GRPCPort int `env:"GRPC_PORT" envDefault:"50052"`
+	HTTPPort int `env:"HTTP_PORT" envDefault:"8081"`
+	// GRPCAuthEnabled requires a JWT bearer token signed with JWTAccessSecure on every gRPC call
+	GRPCAuthEnabled bool `env:"GRPC_AUTH_ENABLED" envDefault:"true"`
 
-var (
-	configOnce sync.Once
-	config     Config
-)
+	// Payment gateway simulator configs
+	GatewaySimLatency       time.Duration `env:"GATEWAY_SIM_LATENCY" envDefault:"100ms"`
+	GatewaySimDeclineTokens []string      `env:"GATEWAY_SIM_DECLINE_TOKENS" envDefault:"tok_decline"`
+	GatewaySimTimeoutTokens []string      `env:"GATEWAY_SIM_TIMEOUT_TOKENS" envDefault:"tok_timeout"`
+	GatewaySimDeclineAmount int64         `env:"GATEWAY_SIM_DECLINE_AMOUNT" envDefault:"0"` // minor units
+
+	// Idempotency configs, a zero TTL keeps keys forever
+	IdempotencyKeyTTL time.Duration `env:"IDEMPOTENCY_KEY_TTL" envDefault:"24h"`
+
+	// Outbox relay configs
+	OutboxPollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" envDefault:"1s"`
+	OutboxBatchSize    int           `env:"OUTBOX_BATCH_SIZE" envDefault:"100"`
+	OutboxMaxAttempts  int           `env:"OUTBOX_MAX_ATTEMPTS" envDefault:"10"`
+	OutboxBaseBackoff  time.Duration `env:"OUTBOX_BASE_BACKOFF" envDefault:"1s"`
+	OutboxMaxBackoff   time.Duration `env:"OUTBOX_MAX_BACKOFF" envDefault:"5m"`
+	OutboxLease        time.Duration `env:"OUTBOX_LEASE" envDefault:"2m"` // covers publishing a whole batch
+
+	// Stuck PENDING payment reconciler configs
+	ReconcilerPollInterval time.Duration `env:"RECONCILER_POLL_INTERVAL" envDefault:"30s"`
+	ReconcilerStuckAfter   time.Duration `env:"RECONCILER_STUCK_AFTER" envDefault:"5m"`
+	ReconcilerBatchSize    int           `env:"RECONCILER_BATCH_SIZE" envDefault:"50"`
+	ReconcilerMaxAttempts  int           `env:"RECONCILER_MAX_ATTEMPTS" envDefault:"5"`
+	ReconcilerBaseBackoff  time.Duration `env:"RECONCILER_BASE_BACKOFF" envDefault:"30s"`
+	ReconcilerMaxBackoff   time.Duration `env:"RECONCILER_MAX_BACKOFF" envDefault:"30m"`
+
+	// Stuck PENDING refund reconciler configs
+	RefundReconcilerPollInterval time.Duration `env:"REFUND_RECONCILER_POLL_INTERVAL" envDefault:"30s"`
+	RefundReconcilerStuckAfter   time.Duration `env:"REFUND_RECONCILER_STUCK_AFTER" envDefault:"5m"`
+	RefundReconcilerBatchSize    int           `env:"REFUND_RECONCILER_BATCH_SIZE" envDefault:"50"`
+
+	// Authorization expiry configs, AuthorizationTTL is how long an authorization can be captured
+	AuthorizationTTL   time.Duration `env:"AUTHORIZATION_TTL" envDefault:"168h"`
+	ExpiryPollInterval time.Duration `env:"EXPIRY_POLL_INTERVAL" envDefault:"1m"`
+	ExpiryBatchSize    int           `env:"EXPIRY_BATCH_SIZE" envDefault:"100"`
+
+	// Merchant webhook dispatcher configs, WebhookTimeout bounds a single request
+	WebhookPollInterval time.Duration `env:"WEBHOOK_POLL_INTERVAL" envDefault:"1s"`
+	WebhookBatchSize    int           `env:"WEBHOOK_BATCH_SIZE" envDefault:"50"`
+	WebhookMaxAttempts  int           `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"8"`
+	WebhookBaseBackoff  time.Duration `env:"WEBHOOK_BASE_BACKOFF" envDefault:"10s"`
+	WebhookMaxBackoff   time.Duration `env:"WEBHOOK_MAX_BACKOFF" envDefault:"1h"`
+	WebhookTimeout      time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"10s"`
+
+	// ShutdownTimeout bounds the drain of every component on SIGTERM
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"`
+
+	// Health check configs, dependencies are checked every interval for /readyz and grpc.health.v1
+	HealthCheckInterval time.Duration `env:"HEALTH_CHECK_INTERVAL" envDefault:"10s"`
+	HealthCheckTimeout  time.Duration `env:"HEALTH_CHECK_TIMEOUT" envDefault:"2s"`
 
-func GetConfig() *Config {
-	configOnce.Do(func() {
-		err := godotenv.Load("./.env")
-		if err != nil {
-			fmt.Println("Error loading .env file:", err)
-		}
-
-		if err := env.Parse(&config); err != nil {
-			fmt.Printf("Failed to parse environment variables: %v\n", err)
-		}
-	})
-	return &config
+	// Tracing configs, TracingExporter is none, stdout for local runs or otlp
+	TracingExporter    string  `env:"TRACING_EXPORTER" envDefault:"none"`
+	TracingEndpoint    string  `env:"OTEL_EXPORTER_OTLP_ENDPOINT" envDefault:"localhost:4317"`
+	TracingInsecure    bool    `env:"OTEL_EXPORTER_OTLP_INSECURE" envDefault:"true"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1.0"`
 }
@@ -0,0 +1,204 @@
+package configloader
+
+import (
+	"errors"
+	"fmt"
+	"github.com/caarlos0/env/v6"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default files, CONFIG_FILE and ENV_FILE point elsewhere. Default files may be missing,
+// files named explicitly must exist.
+const (
+	DefaultConfigFile = "config.yaml"
+	DefaultEnvFile    = ".env"
+)
+
+// Load builds the configuration in layers, each overriding the one before:
+//
+//  1. the envDefault tags of Config
+//  2. the YAML file, keys are the variable names in any case, eg. server_port: 8088
+//  3. the .env file
+//  4. the process environment
+//
+// The result is validated and every problem is reported at once in a *ValidationError.
+func Load() (*Config, error) {
+	values := make(map[string]string)
+
+	configFile, explicit := lookupFile("CONFIG_FILE", DefaultConfigFile)
+	if err := loadYAML(configFile, explicit, values); err != nil {
+		return nil, err
+	}
+
+	envFile, explicit := lookupFile("ENV_FILE", DefaultEnvFile)
+	if err := loadDotEnv(envFile, explicit, values); err != nil {
+		return nil, err
+	}
+
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			values[key] = value
+		}
+	}
+
+	if problems := applyDeprecated(values); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	var cfg Config
+	if err := env.ParseWithFuncs(&cfg, parsers, env.Options{Environment: values}); err != nil {
+		return nil, &ValidationError{Problems: []string{err.Error()}}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// deprecatedKeys maps renamed settings to the setting replacing them and the unit their
+// whole number value was in, so deployments still setting the old names keep working
+var deprecatedKeys = map[string]struct {
+	key  string
+	unit time.Duration
+}{
+	"JWT_ACCESS_TIME_MINUTE": {key: "JWT_ACCESS_TTL", unit: time.Minute},
+	"JWT_REFRESH_TIME_HOUR":  {key: "JWT_REFRESH_TTL", unit: time.Hour},
+}
+
+// applyDeprecated sets the replacement of every deprecated setting present in values,
+// unless the replacement is set as well, and returns the deprecated values it cannot read
+func applyDeprecated(values map[string]string) []string {
+	var problems []string
+	for old, repl := range deprecatedKeys {
+		value, ok := values[old]
+		if !ok {
+			continue
+		}
+		if _, set := values[repl.key]; set {
+			log.Printf("%s is deprecated and ignored, %s is set", old, repl.key)
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || n <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be a positive whole number, got %q", old, value))
+			continue
+		}
+		values[repl.key] = (time.Duration(n) * repl.unit).String()
+		log.Printf("%s is deprecated, use %s=%s", old, repl.key, values[repl.key])
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// parsers covers the field types env/v6 cannot parse itself
+var parsers = map[reflect.Type]env.ParserFunc{
+	reflect.TypeOf(map[string]string{}): parseMap,
+}
+
+// parseMap parses key:value pairs separated by commas, eg. payment.refunded:payment_refunded
+func parseMap(value string) (interface{}, error) {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, ":")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%q is not a key:value pair", pair)
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m, nil
+}
+
+func lookupFile(key, fallback string) (string, bool) {
+	if path, ok := os.LookupEnv(key); ok && path != "" {
+		return path, true
+	}
+	return fallback, false
+}
+
+// loadYAML adds the settings of a flat YAML file to values. Lists become comma separated
+// values and maps key:value pairs, the formats the env tags parse.
+func loadYAML(path string, explicit bool, values map[string]string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !explicit {
+			return nil
+		}
+		return fmt.Errorf("read config file %s: %w", path, err)
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	for key, value := range doc {
+		s, err := yamlValue(value)
+		if err != nil {
+			return fmt.Errorf("config file %s, key %s: %w", path, key, err)
+		}
+		values[strings.ToUpper(key)] = s
+	}
+	return nil
+}
+
+func yamlValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := yamlValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(v))
+		for _, k := range keys {
+			s, err := yamlValue(v[k])
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, k+":"+s)
+		}
+		return strings.Join(pairs, ","), nil
+	case string, bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v, quote it", v)
+	}
+}
+
+func loadDotEnv(path string, explicit bool, values map[string]string) error {
+	dotEnv, err := godotenv.Read(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !explicit {
+			return nil
+		}
+		return fmt.Errorf("read env file %s: %w", path, err)
+	}
+	for key, value := range dotEnv {
+		values[key] = value
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package configloader
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: map[string]string{}},
+		{name: "single pair", value: "payment.refunded:payment_refunded",
+			want: map[string]string{"payment.refunded": "payment_refunded"}},
+		{name: "spaces and empty entries", value: " a : x ,, b:y, ",
+			want: map[string]string{"a": "x", "b": "y"}},
+		{name: "value keeps later colons", value: "a:x:y", want: map[string]string{"a": "x:y"}},
+		{name: "empty value", value: "a:", want: map[string]string{"a": ""}},
+		{name: "missing colon", value: "a:x,b", wantErr: true},
+		{name: "missing key", value: ":x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMap(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMap(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMap(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyDeprecated(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   map[string]string
+		want     map[string]string
+		problems int
+	}{
+		{name: "none set", values: map[string]string{}, want: map[string]string{}},
+		{name: "old names converted",
+			values: map[string]string{"JWT_ACCESS_TIME_MINUTE": "15", "JWT_REFRESH_TIME_HOUR": " 168 "},
+			want: map[string]string{"JWT_ACCESS_TIME_MINUTE": "15", "JWT_ACCESS_TTL": "15m0s",
+				"JWT_REFRESH_TIME_HOUR": " 168 ", "JWT_REFRESH_TTL": "168h0m0s"}},
+		{name: "new name wins",
+			values: map[string]string{"JWT_ACCESS_TIME_MINUTE": "15", "JWT_ACCESS_TTL": "30m"},
+			want:   map[string]string{"JWT_ACCESS_TIME_MINUTE": "15", "JWT_ACCESS_TTL": "30m"}},
+		{name: "not a number",
+			values:   map[string]string{"JWT_ACCESS_TIME_MINUTE": "15m", "JWT_REFRESH_TIME_HOUR": "0"},
+			want:     map[string]string{"JWT_ACCESS_TIME_MINUTE": "15m", "JWT_REFRESH_TIME_HOUR": "0"},
+			problems: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := applyDeprecated(tt.values)
+			if len(problems) != tt.problems {
+				t.Errorf("applyDeprecated() problems = %q, want %d", problems, tt.problems)
+			}
+			if !reflect.DeepEqual(tt.values, tt.want) {
+				t.Errorf("values = %v, want %v", tt.values, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package configloader
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every invalid setting so they can all be fixed in one go
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) required(key, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf("%s is required", key)
+	}
+}
+
+func (v *validator) port(key string, value int) {
+	if value < 1 || value > 65535 {
+		v.addf("%s must be a port between 1 and 65535, got %d", key, value)
+	}
+}
+
+func (v *validator) positive(key string, value int) {
+	if value <= 0 {
+		v.addf("%s must be positive, got %d", key, value)
+	}
+}
+
+func (v *validator) duration(key string, value time.Duration) {
+	if value <= 0 {
+		v.addf("%s must be a positive duration, got %s", key, value)
+	}
+}
+
+// backoff checks a base and max backoff pair, the keys name the settings in the report
+func (v *validator) backoff(baseKey string, base time.Duration, maxKey string, max time.Duration) {
+	v.duration(baseKey, base)
+	if max < base {
+		v.addf("%s (%s) must not be below %s (%s)", maxKey, max, baseKey, base)
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if strings.EqualFold(value, a) {
+			return
+		}
+	}
+	v.addf("%s must be one of %s, got %q", key, strings.Join(allowed, ", "), value)
+}
+
+// Validate checks the settings the service cannot start without and the ones out of
+// range, it returns a *ValidationError listing all of them
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.required("POSTGRES_HOST", c.PostgresHost)
+	v.required("POSTGRES_USER", c.PostgresUser)
+	v.required("POSTGRES_DATABASE", c.PostgresDatabase)
+	v.port("POSTGRES_PORT", c.PostgresPort)
+
+	v.port("SERVER_PORT", c.ServerPort)
+	v.port("GRPC_PORT", c.GRPCPort)
+	v.port("HTTP_PORT", c.HTTPPort)
+	if c.ServerPort == c.GRPCPort || c.ServerPort == c.HTTPPort || c.GRPCPort == c.HTTPPort {
+		v.addf("SERVER_PORT, GRPC_PORT and HTTP_PORT must differ, got %d, %d and %d", c.ServerPort, c.GRPCPort, c.HTTPPort)
+	}
+
+	// The HTTP payment and webhook routes always verify tokens with it
+	v.required("JWT_ACCESS_SECURE", c.JWTAccessSecure)
+	v.duration("JWT_ACCESS_TTL", c.JWTAccessTTL)
+	v.duration("JWT_REFRESH_TTL", c.JWTRefreshTTL)
+
+	if len(c.KafkaBrokers) == 0 {
+		v.addf("KAFKA_BROKERS is required")
+	}
+	v.required("KAFKA_PAYMENT_AUTHORIZED_TOPIC", c.KafkaTopicPaymentAuthorized)
+	v.required("KAFKA_ORDER_CREATED_TOPIC", c.KafkaTopicOrder)
+	v.required("KAFKA_ORDER_GROUP_ID", c.KafkaOrderGroupID)
+	v.required("KAFKA_ORDER_DLQ_TOPIC", c.KafkaTopicOrderDeadLetter)
+	if c.KafkaConsumerMaxRetries < 0 {
+		v.addf("KAFKA_CONSUMER_MAX_RETRIES must not be negative, got %d", c.KafkaConsumerMaxRetries)
+	}
+	v.backoff("KAFKA_CONSUMER_RETRY_BACKOFF", c.KafkaConsumerRetryBackoff, "KAFKA_CONSUMER_MAX_BACKOFF", c.KafkaConsumerMaxBackoff)
+	v.oneOf("KAFKA_EVENT_ENCODING", c.KafkaEventEncoding, "json", "protobuf")
+	v.oneOf("KAFKA_PRODUCER_REQUIRED_ACKS", c.KafkaProducerRequiredAcks, "none", "one", "all")
+	v.oneOf("KAFKA_PRODUCER_COMPRESSION", c.KafkaProducerCompression, "none", "gzip", "snappy", "lz4", "zstd")
+	v.positive("KAFKA_PRODUCER_MAX_ATTEMPTS", c.KafkaProducerMaxAttempts)
+	v.positive("KAFKA_PRODUCER_BATCH_SIZE", c.KafkaProducerBatchSize)
+	v.duration("KAFKA_PRODUCER_BATCH_TIMEOUT", c.KafkaProducerBatchTimeout)
+	if c.KafkaProducerAsync {
+		v.addf("KAFKA_PRODUCER_ASYNC must be false, the outbox relay needs every publish acknowledged")
+	}
+
+	if c.GatewaySimLatency < 0 {
+		v.addf("GATEWAY_SIM_LATENCY must not be negative, got %s", c.GatewaySimLatency)
+	}
+	if c.IdempotencyKeyTTL < 0 {
+		v.addf("IDEMPOTENCY_KEY_TTL must not be negative, got %s", c.IdempotencyKeyTTL)
+	}
+
+	v.duration("OUTBOX_POLL_INTERVAL", c.OutboxPollInterval)
+	v.positive("OUTBOX_BATCH_SIZE", c.OutboxBatchSize)
+	v.positive("OUTBOX_MAX_ATTEMPTS", c.OutboxMaxAttempts)
+	v.backoff("OUTBOX_BASE_BACKOFF", c.OutboxBaseBackoff, "OUTBOX_MAX_BACKOFF", c.OutboxMaxBackoff)
+	v.duration("OUTBOX_LEASE", c.OutboxLease)
+
+	v.duration("RECONCILER_POLL_INTERVAL", c.ReconcilerPollInterval)
+	v.duration("RECONCILER_STUCK_AFTER", c.ReconcilerStuckAfter)
+	v.positive("RECONCILER_BATCH_SIZE", c.ReconcilerBatchSize)
+	v.positive("RECONCILER_MAX_ATTEMPTS", c.ReconcilerMaxAttempts)
+	v.backoff("RECONCILER_BASE_BACKOFF", c.ReconcilerBaseBackoff, "RECONCILER_MAX_BACKOFF", c.ReconcilerMaxBackoff)
+
+	v.duration("REFUND_RECONCILER_POLL_INTERVAL", c.RefundReconcilerPollInterval)
+	v.duration("REFUND_RECONCILER_STUCK_AFTER", c.RefundReconcilerStuckAfter)
+	v.positive("REFUND_RECONCILER_BATCH_SIZE", c.RefundReconcilerBatchSize)
+
+	v.duration("AUTHORIZATION_TTL", c.AuthorizationTTL)
+	v.duration("EXPIRY_POLL_INTERVAL", c.ExpiryPollInterval)
+	v.positive("EXPIRY_BATCH_SIZE", c.ExpiryBatchSize)
+
+	v.duration("WEBHOOK_POLL_INTERVAL", c.WebhookPollInterval)
+	v.positive("WEBHOOK_BATCH_SIZE", c.WebhookBatchSize)
+	v.positive("WEBHOOK_MAX_ATTEMPTS", c.WebhookMaxAttempts)
+	v.backoff("WEBHOOK_BASE_BACKOFF", c.WebhookBaseBackoff, "WEBHOOK_MAX_BACKOFF", c.WebhookMaxBackoff)
+	v.duration("WEBHOOK_TIMEOUT", c.WebhookTimeout)
+
+	v.duration("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	v.duration("HEALTH_CHECK_INTERVAL", c.HealthCheckInterval)
+	v.duration("HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout)
+
+	v.oneOf("TRACING_EXPORTER", c.TracingExporter, "none", "stdout", "otlp")
+	if strings.EqualFold(c.TracingExporter, "otlp") {
+		v.required("OTEL_EXPORTER_OTLP_ENDPOINT", c.TracingEndpoint)
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		v.addf("TRACING_SAMPLE_RATIO must be between 0 and 1, got %g", c.TracingSampleRatio)
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
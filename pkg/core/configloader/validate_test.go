@@ -0,0 +1,89 @@
+package configloader
+
+import (
+	"errors"
+	"github.com/caarlos0/env/v6"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns the defaults of Config with the settings that have none filled in
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	var cfg Config
+	err := env.ParseWithFuncs(&cfg, parsers, env.Options{Environment: map[string]string{
+		"POSTGRES_HOST":     "localhost",
+		"POSTGRES_USER":     "payment",
+		"POSTGRES_DATABASE": "payment",
+		"JWT_ACCESS_SECURE": "secret",
+		"KAFKA_BROKERS":     "localhost:9092",
+	}})
+	if err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	return &cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		// want lists substrings of the expected problems, none means the config is valid
+		want []string
+	}{
+		{name: "defaults", modify: func(c *Config) {}},
+		{name: "missing postgres host", modify: func(c *Config) { c.PostgresHost = " " },
+			want: []string{"POSTGRES_HOST is required"}},
+		{name: "port out of range", modify: func(c *Config) { c.PostgresPort = 70000 },
+			want: []string{"POSTGRES_PORT must be a port"}},
+		{name: "ports clash", modify: func(c *Config) { c.HTTPPort = c.GRPCPort },
+			want: []string{"must differ"}},
+		{name: "no access secret", modify: func(c *Config) { c.GRPCAuthEnabled = false; c.JWTAccessSecure = "" },
+			want: []string{"JWT_ACCESS_SECURE is required"}},
+		{name: "no brokers", modify: func(c *Config) { c.KafkaBrokers = nil },
+			want: []string{"KAFKA_BROKERS is required"}},
+		{name: "unknown encoding", modify: func(c *Config) { c.KafkaEventEncoding = "avro" },
+			want: []string{"KAFKA_EVENT_ENCODING must be one of"}},
+		{name: "encoding is case insensitive", modify: func(c *Config) { c.KafkaEventEncoding = "JSON" }},
+		{name: "async producer", modify: func(c *Config) { c.KafkaProducerAsync = true },
+			want: []string{"KAFKA_PRODUCER_ASYNC must be false"}},
+		{name: "max backoff below base", modify: func(c *Config) {
+			c.OutboxBaseBackoff = time.Minute
+			c.OutboxMaxBackoff = time.Second
+		}, want: []string{"OUTBOX_MAX_BACKOFF (1s) must not be below OUTBOX_BASE_BACKOFF (1m0s)"}},
+		{name: "otlp without endpoint", modify: func(c *Config) { c.TracingExporter = "otlp"; c.TracingEndpoint = "" },
+			want: []string{"OTEL_EXPORTER_OTLP_ENDPOINT is required"}},
+		{name: "every problem reported", modify: func(c *Config) {
+			c.PostgresUser = ""
+			c.WebhookBatchSize = 0
+			c.TracingSampleRatio = 2
+		}, want: []string{"POSTGRES_USER is required", "WEBHOOK_BATCH_SIZE must be positive", "TRACING_SAMPLE_RATIO must be between 0 and 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() = %v, want a *ValidationError", err)
+			}
+			if len(verr.Problems) != len(tt.want) {
+				t.Errorf("Validate() problems = %q, want %d", verr.Problems, len(tt.want))
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want a problem containing %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -26,7 +26,7 @@ func InitDatabase(config *configloader.Config) (*gorm.DB, error) {
 // initializeDatabase creates and configures the database connection
 func initializeDatabase(config *configloader.Config) (*gorm.DB, error) {
 	// Create connection string using environment config
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		config.PostgresHost, config.PostgresPort, config.PostgresUser, config.PostgresPassword, config.PostgresDatabase)
 
 	// Open database connection
@@ -0,0 +1,195 @@
+package health
+
+import (
+	"context"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"payment/pkg/core/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Report statuses
+const (
+	StatusOK       = "ok"
+	StatusDown     = "down"
+	StatusStarting = "starting"
+	StatusStopping = "stopping"
+)
+
+// Check reports whether a dependency is usable, it must return once ctx is done
+type Check func(ctx context.Context) error
+
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type Report struct {
+	Status    string                 `json:"status"`
+	Checks    map[string]CheckResult `json:"checks,omitempty"`
+	CheckedAt *time.Time             `json:"checked_at,omitempty"`
+}
+
+// Ready reports whether every dependency was up on the last run
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the dependency checks periodically and keeps the last report. The same
+// state backs /readyz and the grpc.health.v1 service, so both flip together, and both
+// stay NOT_SERVING once Shutdown is called.
+type Checker struct {
+	interval time.Duration
+	timeout  time.Duration
+	services []string
+	checks   []namedCheck
+
+	mu       sync.RWMutex
+	report   Report
+	stopping atomic.Bool
+	grpc     *health.Server
+}
+
+// NewChecker builds a checker running every interval with checks bounded by timeout.
+// services are the gRPC service names reported along with the overall "" service.
+func NewChecker(interval, timeout time.Duration, services ...string) *Checker {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	c := &Checker{
+		interval: interval,
+		timeout:  timeout,
+		services: append([]string{""}, services...),
+		report:   Report{Status: StatusStarting},
+		grpc:     health.NewServer(),
+	}
+	c.setServing(healthpb.HealthCheckResponse_NOT_SERVING)
+	return c
+}
+
+// Add registers a check, it must be called before Run
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// GRPCServer is the grpc.health.v1 implementation to register on the gRPC server
+func (c *Checker) GRPCServer() healthpb.HealthServer {
+	return c.grpc
+}
+
+// Run checks the dependencies right away and then every interval until ctx is cancelled
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.CheckNow(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckNow runs every check concurrently and stores the report
+func (c *Checker) CheckNow(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make(map[string]CheckResult, len(c.checks))
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := nc.check(ctx)
+			res := CheckResult{Status: StatusOK, DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Status = StatusDown
+				res.Error = err.Error()
+			}
+			mu.Lock()
+			results[nc.name] = res
+			mu.Unlock()
+		}(nc)
+	}
+	wg.Wait()
+
+	now := time.Now()
+	report := Report{Status: StatusOK, Checks: results, CheckedAt: &now}
+	for _, res := range results {
+		if res.Status != StatusOK {
+			report.Status = StatusDown
+		}
+	}
+	c.store(report)
+	return c.Report()
+}
+
+// Report returns the last report, StatusStopping once Shutdown is called
+func (c *Checker) Report() Report {
+	c.mu.RLock()
+	report := c.report
+	c.mu.RUnlock()
+	if c.stopping.Load() {
+		report.Status = StatusStopping
+	}
+	return report
+}
+
+// Shutdown marks the service NOT_SERVING for good so load balancers drain it before
+// the servers stop
+func (c *Checker) Shutdown() {
+	if c.stopping.Swap(true) {
+		return
+	}
+	logger.WithTag("HealthChecker|Shutdown").Info("health set to NOT_SERVING for shutdown")
+	c.grpc.Shutdown()
+}
+
+func (c *Checker) store(report Report) {
+	c.mu.Lock()
+	previous := c.report.Status
+	c.report = report
+	c.mu.Unlock()
+
+	if previous != report.Status {
+		log := logger.WithTag("HealthChecker|store")
+		for name, res := range report.Checks {
+			if res.Status != StatusOK {
+				log = log.WithField(name, res.Error)
+			}
+		}
+		log.Infof("health changed from %s to %s", previous, report.Status)
+	}
+
+	if report.Ready() {
+		c.setServing(healthpb.HealthCheckResponse_SERVING)
+	} else {
+		c.setServing(healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+}
+
+// setServing is ignored by the gRPC health server after Shutdown
+func (c *Checker) setServing(status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range c.services {
+		c.grpc.SetServingStatus(service, status)
+	}
+}
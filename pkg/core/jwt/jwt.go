@@ -4,11 +4,9 @@ import (
 	"context"
 	"github.com/golang-jwt/jwt/v4"
 	model "payment/internal/models"
-	"payment/pkg/core/configloader"
 	"payment/pkg/core/logger"
 	"payment/pkg/http/utils"
 	"payment/pkg/http/utils/app_errors"
-	"strconv"
 	"time"
 )
 
@@ -24,29 +22,31 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+// TokenConfig holds the signing secret and the lifetime of each token type, eg. from the
+// JWT_* settings. Access and refresh tokens are both signed with AccessSecret.
+type TokenConfig struct {
+	AccessSecret string
+	AccessTTL    time.Duration
+	RefreshTTL   time.Duration
+}
+
 // GenerateJWTToken generates a JWT token (refresh or access)
 func GenerateJWTTokenUser(context context.Context,
+	cfg TokenConfig,
 	userRole string,
 	tokenType string) (appToken *AppToken, err error) {
 
 	log := logger.WithCtx(context, "GenerateJWTTokenUser")
 
-	config := configloader.GetConfig()
-
-	JWTSecureKey := config.JWTAccessSecure
-	JWTExpTime := config.JWTAccessTimeMinute
-
-	tokenTimeConverted, err := strconv.ParseInt(JWTExpTime, 10, 64)
-	if err != nil {
-		return
-	}
-
-	tokenTimeUnix := time.Duration(tokenTimeConverted)
+	var (
+		JWTSecureKey  string
+		tokenTimeUnix time.Duration
+	)
 	switch tokenType {
 	case UserRefresh:
-		tokenTimeUnix *= time.Hour
+		JWTSecureKey, tokenTimeUnix = cfg.AccessSecret, cfg.RefreshTTL
 	case UserAccess:
-		tokenTimeUnix *= time.Minute
+		JWTSecureKey, tokenTimeUnix = cfg.AccessSecret, cfg.AccessTTL
 
 	default:
 		err = app_errors.AppError("Fail to Authorized", app_errors.StatusUnauthorized)
@@ -2,9 +2,17 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"log"
-	"strings"
+	"payment/pkg/core/backoff"
+	"payment/pkg/core/metrics"
+	"payment/pkg/core/tracing"
+	"strconv"
+	"time"
 )
 
 type ReaderWrapper interface {
@@ -13,19 +21,62 @@ type ReaderWrapper interface {
 	Close() error
 }
 
+// Dead-letter headers recording where a message came from and why it failed
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderFailureReason     = "x-failure-reason"
+	HeaderAttempts          = "x-attempts"
+)
+
+// ErrPermanent marks handler errors that retrying cannot fix, eg. undecodable payloads
+var ErrPermanent = errors.New("permanent failure")
+
+// Permanent wraps err so Listen sends the message to the dead-letter topic without retrying
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+type WriterWrapper interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Decoder turns a fetched message into the bytes handed to the handler
+type Decoder interface {
+	Decode(msg kafka.Message) ([]byte, error)
+}
+
 type Consumer struct {
 	Reader ReaderWrapper
+	// DeadLetter receives the messages that could not be handled. Without it the
+	// consumer stops at the first such message and leaves it uncommitted.
+	DeadLetter WriterWrapper
+	Retry      RetryPolicy
+	// Decoder, when set, decodes every message before it reaches the handler
+	Decoder Decoder
 }
 
-func NewConsumer(brokers, topic, groupID string) *Consumer {
+// errNoDeadLetter stops Listen on a failed message when no dead-letter writer is set
+var errNoDeadLetter = errors.New("no dead-letter topic configured")
+
+// NewConsumer reads topic in the consumer group groupID, failed messages go to deadLetter
+func NewConsumer(brokers []string, topic, groupID string, deadLetter WriterWrapper) *Consumer {
 	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{brokers},
+		Brokers: brokers,
 		GroupID: groupID, // using consumer group
 		Topic:   topic,   // \*set Topic when using GroupID\*
 		// GroupTopics: []string{topic}, // \*do NOT set this together with Topic\*
 	})
 
-	return &Consumer{Reader: r}
+	return &Consumer{Reader: &KafkaReader{reader: r}, DeadLetter: deadLetter}
 }
 
 // Adapter implement ReaderWrapper
@@ -45,38 +96,154 @@ func (r *KafkaReader) Close() error {
 	return r.reader.Close()
 }
 
-func NewReader(brokers, topic, groupID string) ReaderWrapper {
-	// split brokers the same way as NewWriter
-	addrs := strings.Split(brokers, ",")
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  addrs,
-		Topic:    topic,
-		GroupID:  groupID,
-		MinBytes: 10e3, // 10KB
-		MaxBytes: 10e6, // 10MB
-	})
-	return &KafkaReader{reader: r}
-}
-
-func (c *Consumer) Listen(ctx context.Context, handler func([]byte)) {
+// Listen delivers every message to handler and commits its offset once the message
+// is handled. Failures are retried with exponential backoff up to Retry.MaxRetries
+// times (errors wrapped with Permanent are not retried); exhausted messages are
+// written to the dead-letter topic before their offset is committed. Fetch errors, eg.
+// while the brokers are down, are retried with the same backoff. Handlers run in a
+// consumer span continuing the trace found in the message headers.
+func (c *Consumer) Listen(ctx context.Context, handler func(context.Context, []byte) error) {
+	fetchFailures := 0
 	for {
 		msg, err := c.Reader.FetchMessage(ctx)
 		if err != nil {
 			// if ctx cancelled, FetchMessage should return an error; exit loop
+			if ctx.Err() != nil {
+				log.Printf("consumer context canceled, exiting listen: %v", ctx.Err())
+				return
+			}
+			fetchFailures++
+			log.Printf("Error reading message: %v", err)
 			select {
 			case <-ctx.Done():
 				log.Printf("consumer context canceled, exiting listen: %v", ctx.Err())
 				return
-			default:
+			case <-time.After(c.backoff(fetchFailures)):
 			}
-			log.Printf("Error reading message: %v", err)
 			continue
 		}
+		fetchFailures = 0
+		metrics.ConsumerLag.WithLabelValues(msg.Topic, strconv.Itoa(msg.Partition)).
+			Set(float64(msg.HighWaterMark - msg.Offset - 1))
 
-		handler(msg.Value)
+		attempts, err := c.traceHandle(ctx, msg, handler)
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Printf("consumer context canceled, message left uncommitted: %v", ctx.Err())
+				return
+			}
+			if err := c.deadLetter(ctx, msg, attempts, err); err != nil {
+				log.Printf("consumer stopped, message topic=%s partition=%d offset=%d left uncommitted: %v",
+					msg.Topic, msg.Partition, msg.Offset, err)
+				return
+			}
+		}
 
-		if err := c.Reader.CommitMessages(ctx, msg); err != nil {
+		// Commit even when shutdown started while the message was handled
+		if err := c.Reader.CommitMessages(context.WithoutCancel(ctx), msg); err != nil {
 			log.Printf("Failed to commit message: %v", err)
 		}
 	}
 }
+
+// traceHandle runs handle in a consumer span, a child of the producer span of msg
+func (c *Consumer) traceHandle(ctx context.Context, msg kafka.Message, handler func(context.Context, []byte) error) (int, error) {
+	msgCtx := tracing.Extract(ctx, tracing.KafkaCarrier{Headers: &msg.Headers})
+	msgCtx, span := tracing.StartKind(msgCtx, "process "+msg.Topic, trace.SpanKindConsumer,
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", msg.Topic),
+		attribute.Int("messaging.destination.partition.id", msg.Partition),
+		attribute.Int64("messaging.kafka.offset", msg.Offset),
+	)
+	attempts, err := c.handle(msgCtx, msg, handler)
+	span.SetAttributes(attribute.Int("messaging.attempts", attempts))
+	tracing.End(span, err)
+	return attempts, err
+}
+
+// handle runs handler with the retry policy and returns the number of attempts made
+// and the last error when the message could not be handled
+func (c *Consumer) handle(ctx context.Context, msg kafka.Message, handler func(context.Context, []byte) error) (int, error) {
+	value := msg.Value
+	if c.Decoder != nil {
+		decoded, err := c.Decoder.Decode(msg)
+		if err != nil {
+			log.Printf("Failed to decode message topic=%s partition=%d offset=%d: %v",
+				msg.Topic, msg.Partition, msg.Offset, err)
+			return 1, Permanent(err)
+		}
+		value = decoded
+	}
+
+	// The handler is not cancelled with ctx so a payment in flight completes during
+	// shutdown, retries still stop once ctx is done
+	handlerCtx := context.WithoutCancel(ctx)
+	for attempt := 1; ; attempt++ {
+		err := handler(handlerCtx, value)
+		if err == nil {
+			return attempt, nil
+		}
+		log.Printf("Failed to handle message topic=%s partition=%d offset=%d attempt=%d: %v",
+			msg.Topic, msg.Partition, msg.Offset, attempt, err)
+
+		if errors.Is(err, ErrPermanent) || attempt > c.Retry.MaxRetries {
+			return attempt, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(c.backoff(attempt)):
+		}
+	}
+}
+
+// backoff returns the wait after the given number of consecutive failures
+func (c *Consumer) backoff(failures int) time.Duration {
+	base := c.Retry.Backoff
+	if base <= 0 {
+		base = time.Second
+	}
+	max := c.Retry.MaxBackoff
+	if max <= 0 {
+		// An unset cap still stops growing at a minute
+		max = time.Minute
+	}
+	return backoff.Exponential(base, max, failures)
+}
+
+// deadLetter publishes msg to the dead-letter topic, retrying until it is written
+// or ctx is cancelled so an exhausted message is never dropped
+func (c *Consumer) deadLetter(ctx context.Context, msg kafka.Message, attempts int, cause error) error {
+	if c.DeadLetter == nil {
+		return fmt.Errorf("%w: %w", errNoDeadLetter, cause)
+	}
+
+	dlq := kafka.Message{
+		Key:   msg.Key,
+		Value: msg.Value,
+		Headers: append(append([]kafka.Header{}, msg.Headers...),
+			kafka.Header{Key: HeaderOriginalTopic, Value: []byte(msg.Topic)},
+			kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(msg.Partition))},
+			kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+			kafka.Header{Key: HeaderFailureReason, Value: []byte(cause.Error())},
+			kafka.Header{Key: HeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+		),
+	}
+
+	for failures := 1; ; failures++ {
+		err := c.DeadLetter.WriteMessages(ctx, dlq)
+		if err == nil {
+			log.Printf("Message topic=%s partition=%d offset=%d sent to dead-letter topic: %v",
+				msg.Topic, msg.Partition, msg.Offset, cause)
+			return nil
+		}
+		log.Printf("Failed to write dead-letter message: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.backoff(failures)):
+		}
+	}
+}
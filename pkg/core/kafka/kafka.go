@@ -1,22 +1,83 @@
 package kafka
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/segmentio/kafka-go"
-	"payment/pkg/core/kafka/payment"
 	"strings"
+	"time"
 )
 
-type App struct {
-	Producer *payment.Producer
-	Consumer *Consumer
+// WriterConfig holds the delivery settings shared by every writer of the service
+type WriterConfig struct {
+	Brokers []string
+	// Topic is left empty when every message names its own topic
+	Topic string
+	// RequiredAcks is none, one or all
+	RequiredAcks string
+	MaxAttempts  int
+	BatchSize    int
+	BatchTimeout time.Duration
+	// Compression is empty for none, or gzip, snappy, lz4 or zstd
+	Compression string
+	// Async makes WriteMessages return before delivery, errors only reach the Completion hook
+	Async bool
 }
 
-func NewWriter(brokers, topic string) *kafka.Writer {
-	return &kafka.Writer{
-		Addr:     kafka.TCP(strings.Split(brokers, ",")...),
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+var compressions = map[string]kafka.Compression{
+	"gzip":   kafka.Gzip,
+	"snappy": kafka.Snappy,
+	"lz4":    kafka.Lz4,
+	"zstd":   kafka.Zstd,
+}
+
+// NewWriter builds a writer from cfg. Messages are balanced by key hash so events of
+// the same aggregate always land on the same partition.
+func NewWriter(cfg WriterConfig) (*kafka.Writer, error) {
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafka.Hash{},
+		MaxAttempts:  cfg.MaxAttempts,
+		BatchSize:    cfg.BatchSize,
+		BatchTimeout: cfg.BatchTimeout,
+		Async:        cfg.Async,
+	}
+
+	acks := cfg.RequiredAcks
+	if acks == "" {
+		acks = "all"
+	}
+	if err := w.RequiredAcks.UnmarshalText([]byte(strings.ToLower(acks))); err != nil {
+		return nil, err
+	}
+
+	if cfg.Compression != "" && cfg.Compression != "none" {
+		codec, ok := compressions[strings.ToLower(cfg.Compression)]
+		if !ok {
+			return nil, fmt.Errorf("unknown kafka compression %q", cfg.Compression)
+		}
+		w.Compression = codec
+	}
+	return w, nil
+}
+
+// Ping dials the brokers and succeeds as soon as one of them accepts a connection, the
+// clients discover the rest of the cluster through it
+func Ping(ctx context.Context, brokers []string) error {
+	if len(brokers) == 0 {
+		return errors.New("no kafka brokers configured")
+	}
+	var errs []error
+	for _, broker := range brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err == nil {
+			return conn.Close()
+		}
+		errs = append(errs, err)
 	}
+	return errors.Join(errs...)
 }
 
 func Close(writer *kafka.Writer, reader *kafka.Reader) {
@@ -0,0 +1,56 @@
+package payment
+
+import (
+	"encoding/json"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+// SpecVersion is the CloudEvents version the envelope follows
+const SpecVersion = "1.0"
+
+// Kafka headers carrying the envelope attributes, following the CloudEvents Kafka binding
+const (
+	HeaderID            = "ce_id"
+	HeaderType          = "ce_type"
+	HeaderSource        = "ce_source"
+	HeaderTime          = "ce_time"
+	HeaderSpecVersion   = "ce_specversion"
+	HeaderSchemaVersion = "ce_schemaversion"
+	HeaderTraceID       = "ce_traceid"
+	HeaderContentType   = "content-type"
+)
+
+const contentTypeJSON = "application/json"
+
+// Envelope is a CloudEvents style wrapper around an event payload. The attributes are
+// sent both as Kafka headers, so consumers can route without decoding, and in the
+// message value together with Data.
+type Envelope struct {
+	ID              string          `json:"id"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	Time            time.Time       `json:"time"`
+	SpecVersion     string          `json:"specversion"`
+	SchemaVersion   string          `json:"schemaVersion"`
+	TraceID         string          `json:"traceId,omitempty"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// Headers returns the envelope attributes as Kafka headers
+func (e *Envelope) Headers() []kafka.Header {
+	headers := []kafka.Header{
+		{Key: HeaderID, Value: []byte(e.ID)},
+		{Key: HeaderType, Value: []byte(e.Type)},
+		{Key: HeaderSource, Value: []byte(e.Source)},
+		{Key: HeaderTime, Value: []byte(e.Time.UTC().Format(time.RFC3339Nano))},
+		{Key: HeaderSpecVersion, Value: []byte(e.SpecVersion)},
+		{Key: HeaderSchemaVersion, Value: []byte(e.SchemaVersion)},
+		{Key: HeaderContentType, Value: []byte(e.DataContentType)},
+	}
+	if e.TraceID != "" {
+		headers = append(headers, kafka.Header{Key: HeaderTraceID, Value: []byte(e.TraceID)})
+	}
+	return headers
+}
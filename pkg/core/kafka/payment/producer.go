@@ -2,35 +2,166 @@ package payment
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/segmentio/kafka-go"
-	"log"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"payment/pkg/core/logger"
+	"payment/pkg/core/metrics"
+	"payment/pkg/core/tracing"
+	"sync/atomic"
 )
 
+// Producer publishes event envelopes, routing every event type to its topic
 type Producer struct {
 	Writer *kafka.Writer
+	// Topics maps an event type, eg. payment.authorized, to its topic
+	Topics map[string]string
+	// DefaultTopic receives event types missing from Topics
+	DefaultTopic string
+	// Serializer encodes the envelopes, JSON when nil
+	Serializer Serializer
+
+	delivered atomic.Int64
+	failed    atomic.Int64
+}
+
+// ProducerStats counts the delivery outcomes reported by the writer
+type ProducerStats struct {
+	Delivered int64
+	Failed    int64
+	// Writer holds the writer stats since the previous call to Stats
+	Writer kafka.WriterStats
+}
+
+// DeliveryError is returned when the writer could not deliver an event
+type DeliveryError struct {
+	Topic     string
+	EventType string
+	EventID   string
+	Err       error
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("deliver %s event %s to topic %s: %v", e.EventType, e.EventID, e.Topic, e.Err)
 }
 
-func NewPaymentProducer(brokers []string, topic string) *Producer {
-	log.Printf("Creating Kafka producer for brokers: %v, topic: %s", brokers, topic)
-	return &Producer{
-		Writer: &kafka.Writer{
-			Addr:     kafka.TCP(brokers...),
-			Topic:    topic,
-			Balancer: &kafka.Hash{},
-		},
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// NewPaymentProducer publishes through writer, which must not have a Topic set.
+// The writer's Completion hook is taken over to count and log delivery outcomes.
+func NewPaymentProducer(writer *kafka.Writer, defaultTopic string, topics map[string]string, serializer Serializer) *Producer {
+	p := &Producer{
+		Writer:       writer,
+		Topics:       topics,
+		DefaultTopic: defaultTopic,
+		Serializer:   serializer,
 	}
+	writer.Completion = p.completion
+
+	logger.WithTag("PaymentProducer|New").WithFields(logrus.Fields{
+		"default_topic": defaultTopic,
+		"topics":        topics,
+		"content_type":  serializer.ContentType(),
+		"required_acks": writer.RequiredAcks.String(),
+		"async":         writer.Async,
+	}).Info("kafka producer created")
+	return p
 }
 
-func (p *Producer) SendMessage(ctx context.Context, key, value string) error {
+// TopicFor returns the topic events of eventType are published to
+func (p *Producer) TopicFor(eventType string) string {
+	if topic, ok := p.Topics[eventType]; ok && topic != "" {
+		return topic
+	}
+	return p.DefaultTopic
+}
 
-	log.Printf("Sending message to topic %s: key=%s, value=%s", p.Writer.Topic, key, value)
+// Publish sends env keyed by key, so events of the same aggregate stay ordered. In async
+// mode it returns once the message is queued and failures are only logged and counted.
+// The trace context of the publish span travels in the message headers.
+func (p *Producer) Publish(ctx context.Context, key string, env *Envelope) (err error) {
+	if env.SpecVersion == "" {
+		env.SpecVersion = SpecVersion
+	}
+	serializer := p.Serializer
+	if serializer == nil {
+		serializer = JSONSerializer{}
+	}
+	topic := p.TopicFor(env.Type)
+	ctx, span := tracing.StartKind(ctx, "publish "+topic, trace.SpanKindProducer,
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", topic),
+		attribute.String("messaging.message.id", env.ID),
+		attribute.String("event.type", env.Type),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	env.DataContentType = serializer.ContentType()
+	value, err := serializer.Marshal(env)
+	if err != nil {
+		metrics.ProducerMessages.WithLabelValues(topic, "encode_failed").Inc()
+		return err
+	}
+
+	headers := env.Headers()
+	tracing.Inject(ctx, tracing.KafkaCarrier{Headers: &headers})
+
+	err = p.Writer.WriteMessages(ctx, kafka.Message{
+		Topic:   topic,
+		Key:     []byte(key),
+		Value:   value,
+		Headers: headers,
+	})
+	if err != nil {
+		// Chỉ gửi một message nên lấy lỗi riêng của message đó
+		var writeErrs kafka.WriteErrors
+		if errors.As(err, &writeErrs) && len(writeErrs) == 1 && writeErrs[0] != nil {
+			err = writeErrs[0]
+		}
+		return &DeliveryError{Topic: topic, EventType: env.Type, EventID: env.ID, Err: err}
+	}
+	return nil
+}
+
+// completion is called by the writer once a batch is acknowledged or has failed
+func (p *Producer) completion(messages []kafka.Message, err error) {
+	if len(messages) == 0 {
+		return
+	}
+	// Mọi message trong một lần gọi đều thuộc cùng một partition, cùng topic
+	topic := messages[0].Topic
+	if err == nil {
+		p.delivered.Add(int64(len(messages)))
+		metrics.ProducerMessages.WithLabelValues(topic, "delivered").Add(float64(len(messages)))
+		return
+	}
+	p.failed.Add(int64(len(messages)))
+	metrics.ProducerMessages.WithLabelValues(topic, "failed").Add(float64(len(messages)))
 
-	msg := kafka.Message{
-		Key:   []byte(key),
-		Value: []byte(value),
+	log := logger.WithTag("PaymentProducer|completion")
+	for _, msg := range messages {
+		logger.LogError(log.WithFields(logrus.Fields{
+			"topic":      msg.Topic,
+			"partition":  msg.Partition,
+			"key":        string(msg.Key),
+			"event_type": header(msg.Headers, HeaderType),
+			"event_id":   header(msg.Headers, HeaderID),
+		}), err, "event delivery failed")
 	}
+}
 
-	return p.Writer.WriteMessages(ctx, msg)
+// Stats returns the delivery counters since start and the writer stats
+func (p *Producer) Stats() ProducerStats {
+	return ProducerStats{
+		Delivered: p.delivered.Load(),
+		Failed:    p.failed.Load(),
+		Writer:    p.Writer.Stats(),
+	}
 }
 
 func (p *Producer) Close() error {
@@ -0,0 +1,174 @@
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "payment/pkg/proto/paymentpb"
+	"strings"
+)
+
+// Event encodings selectable with KAFKA_EVENT_ENCODING
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+)
+
+const contentTypeProtobuf = "application/protobuf"
+
+const eventTypeRefunded = "payment.refunded"
+
+// Serializer encodes envelopes into message values. ContentType is advertised in the
+// content-type header so consumers pick the matching decoder.
+type Serializer interface {
+	ContentType() string
+	Marshal(env *Envelope) ([]byte, error)
+	Unmarshal(value []byte) (*Envelope, error)
+}
+
+// NewSerializer returns the serializer for encoding, json when encoding is empty
+func NewSerializer(encoding string) (Serializer, error) {
+	switch strings.ToLower(encoding) {
+	case "", EncodingJSON:
+		return JSONSerializer{}, nil
+	case EncodingProtobuf:
+		return ProtobufSerializer{}, nil
+	}
+	return nil, fmt.Errorf("unknown event encoding %q", encoding)
+}
+
+// JSONSerializer writes the whole envelope as JSON
+type JSONSerializer struct{}
+
+func (JSONSerializer) ContentType() string {
+	return contentTypeJSON
+}
+
+func (JSONSerializer) Marshal(env *Envelope) ([]byte, error) {
+	return json.Marshal(env)
+}
+
+func (JSONSerializer) Unmarshal(value []byte) (*Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(value, &env); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+// ProtobufSerializer writes a paymentpb.EventEnvelope whose data is the event payload
+// encoded as the message registered for its type
+type ProtobufSerializer struct{}
+
+func (ProtobufSerializer) ContentType() string {
+	return contentTypeProtobuf
+}
+
+func (ProtobufSerializer) Marshal(env *Envelope) ([]byte, error) {
+	msg, err := eventMessage(env.Type)
+	if err != nil {
+		return nil, err
+	}
+	// Payload mới có thể có thêm field, bỏ qua để consumer cũ vẫn đọc được
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(env.Data, msg); err != nil {
+		return nil, fmt.Errorf("encode %s data: %w", env.Type, err)
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&pb.EventEnvelope{
+		Id:            env.ID,
+		Type:          env.Type,
+		Source:        env.Source,
+		Time:          timestamppb.New(env.Time),
+		SpecVersion:   env.SpecVersion,
+		SchemaVersion: env.SchemaVersion,
+		TraceId:       env.TraceID,
+		Data:          data,
+	})
+}
+
+// Unmarshal decodes an EventEnvelope, Data is returned as JSON so handlers see the same
+// payload whichever encoding the producer used
+func (ProtobufSerializer) Unmarshal(value []byte) (*Envelope, error) {
+	var pe pb.EventEnvelope
+	if err := proto.Unmarshal(value, &pe); err != nil {
+		return nil, err
+	}
+	msg, err := eventMessage(pe.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := proto.Unmarshal(pe.Data, msg); err != nil {
+		return nil, fmt.Errorf("decode %s data: %w", pe.Type, err)
+	}
+	data, err := (protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}).Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		ID:              pe.Id,
+		Type:            pe.Type,
+		Source:          pe.Source,
+		Time:            pe.Time.AsTime(),
+		SpecVersion:     pe.SpecVersion,
+		SchemaVersion:   pe.SchemaVersion,
+		TraceID:         pe.TraceId,
+		DataContentType: contentTypeJSON,
+		Data:            data,
+	}, nil
+}
+
+// eventMessage returns an empty message for the data of eventType
+func eventMessage(eventType string) (proto.Message, error) {
+	switch {
+	case eventType == eventTypeRefunded:
+		return &pb.RefundEvent{}, nil
+	case strings.HasPrefix(eventType, "payment."):
+		return &pb.PaymentEvent{}, nil
+	}
+	return nil, fmt.Errorf("no protobuf message for event type %q", eventType)
+}
+
+// Decoder turns event messages into their JSON envelope whatever serializer produced them
+type Decoder struct {
+	serializers map[string]Serializer
+}
+
+func NewDecoder() *Decoder {
+	return &Decoder{serializers: map[string]Serializer{
+		contentTypeJSON:     JSONSerializer{},
+		contentTypeProtobuf: ProtobufSerializer{},
+	}}
+}
+
+// Decode returns the value of msg as JSON. Messages without a content-type header or
+// already in JSON are returned unchanged.
+func (d *Decoder) Decode(msg kafka.Message) ([]byte, error) {
+	contentType := header(msg.Headers, HeaderContentType)
+	if contentType == "" || contentType == contentTypeJSON {
+		return msg.Value, nil
+	}
+	s, ok := d.serializers[contentType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported content type %q", contentType)
+	}
+	env, err := s.Unmarshal(msg.Value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}
+
+func header(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Key, key) {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package payment
+
+import (
+	"encoding/json"
+	"github.com/segmentio/kafka-go"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testEnvelope(eventType string, data string) *Envelope {
+	return &Envelope{
+		ID:              "3f1c2a8e-0000-4000-8000-000000000001",
+		Type:            eventType,
+		Source:          "payment",
+		Time:            time.Date(2025, 11, 5, 9, 30, 0, 0, time.UTC),
+		SpecVersion:     SpecVersion,
+		SchemaVersion:   "1",
+		TraceID:         "4bf92f3577b34da6a3ce929d0e0e4736",
+		DataContentType: contentTypeJSON,
+		Data:            json.RawMessage(data),
+	}
+}
+
+func TestNewSerializer(t *testing.T) {
+	tests := []struct {
+		encoding    string
+		contentType string
+		wantErr     bool
+	}{
+		{encoding: "", contentType: contentTypeJSON},
+		{encoding: "json", contentType: contentTypeJSON},
+		{encoding: "JSON", contentType: contentTypeJSON},
+		{encoding: "protobuf", contentType: contentTypeProtobuf},
+		{encoding: "avro", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			s, err := NewSerializer(tt.encoding)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSerializer(%q) error = %v, want error %v", tt.encoding, err, tt.wantErr)
+			}
+			if err == nil && s.ContentType() != tt.contentType {
+				t.Errorf("ContentType() = %q, want %q", s.ContentType(), tt.contentType)
+			}
+		})
+	}
+}
+
+func TestSerializerRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		serializer Serializer
+		env        *Envelope
+	}{
+		{name: "json payment event", serializer: JSONSerializer{},
+			env: testEnvelope("payment.authorized", `{"payment_id":"p-1","amount":1234,"currency":"USD"}`)},
+		{name: "json refund event", serializer: JSONSerializer{},
+			env: testEnvelope(eventTypeRefunded, `{"refund_id":"r-1","amount":500}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := tt.serializer.Marshal(tt.env)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			got, err := tt.serializer.Unmarshal(value)
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			assertJSONEqual(t, got.Data, tt.env.Data)
+			got.Data, tt.env.Data = nil, nil
+			if !got.Time.Equal(tt.env.Time) {
+				t.Errorf("Time = %s, want %s", got.Time, tt.env.Time)
+			}
+			got.Time = tt.env.Time
+			if !reflect.DeepEqual(got, tt.env) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, tt.env)
+			}
+		})
+	}
+}
+
+func TestDecoderPassesJSONThrough(t *testing.T) {
+	value := []byte(`{"order_id":"o-1"}`)
+	tests := []struct {
+		name    string
+		headers []kafka.Header
+	}{
+		{name: "no content type"},
+		{name: "json content type", headers: []kafka.Header{{Key: HeaderContentType, Value: []byte(contentTypeJSON)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewDecoder().Decode(kafka.Message{Value: value, Headers: tt.headers})
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if string(got) != string(value) {
+				t.Errorf("Decode() = %s, want %s", got, value)
+			}
+		})
+	}
+
+	_, err := NewDecoder().Decode(kafka.Message{Value: value,
+		Headers: []kafka.Header{{Key: HeaderContentType, Value: []byte("application/avro")}}})
+	if err == nil {
+		t.Error("Decode() of an unsupported content type succeeded")
+	}
+}
+
+func assertJSONEqual(t *testing.T, got, want []byte) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("decode %s: %v", got, err)
+	}
+	if err := json.Unmarshal(want, &w); err != nil {
+		t.Fatalf("decode %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("data = %s, want %s", got, want)
+	}
+}
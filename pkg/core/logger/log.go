@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -57,6 +58,10 @@ func WithCtx(ctx context.Context, tag string) *logrus.Entry {
 	if requestID, ok := ctx.Value("x-request-id").(string); ok && requestID != "" {
 		l = l.WithField("x-request-id", requestID)
 	}
+	// trace_id lets log lines be joined with the spans of the same request
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		l = l.WithField("trace_id", sc.TraceID().String())
+	}
 	return l
 }
 
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+// Namespace prefixes every metric of the service
+const Namespace = "payment"
+
+var (
+	// GatewayDuration observes calls to the payment gateway by operation and outcome
+	GatewayDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "gateway",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of payment gateway calls.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "outcome"})
+
+	// GRPCDuration observes handled gRPC calls by method and status code
+	GRPCDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "grpc",
+		Name:      "server_handling_seconds",
+		Help:      "Latency of gRPC calls handled by the server.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	// HTTPDuration observes Gin requests by method, route template and status
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests served by the Gin router.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// DBQueryDuration observes GORM statements by operation and table
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Duration of database statements run through GORM.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table", "result"})
+
+	// ProducerMessages counts events by topic and delivery result (delivered, failed)
+	ProducerMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "kafka_producer",
+		Name:      "messages_total",
+		Help:      "Events handed to kafka by delivery result.",
+	}, []string{"topic", "result"})
+
+	// ConsumerLag is how many messages a partition's consumer is behind its high watermark
+	ConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "kafka_consumer",
+		Name:      "lag",
+		Help:      "Messages between the last fetched offset and the partition high watermark.",
+	}, []string{"topic", "partition"})
+)
+
+// Handler serves the default registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// GinHandler serves Handler on a Gin route
+func GinHandler() gin.HandlerFunc {
+	return gin.WrapH(Handler())
+}
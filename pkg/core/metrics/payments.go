@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+// StatusCounter returns the number of payments in every status
+type StatusCounter func(ctx context.Context) (map[string]int64, error)
+
+var paymentsByStatusDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(Namespace, "payments", "by_status"),
+	"Payments currently in each status.",
+	[]string{"status"}, nil,
+)
+
+var scrapeErrorsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(Namespace, "payments", "scrape_error"),
+	"1 when the last count of payments by status failed.",
+	nil, nil,
+)
+
+// paymentStatusCollector counts payments on every scrape, so the gauge always agrees
+// with the database whichever instance moved the payments
+type paymentStatusCollector struct {
+	count   StatusCounter
+	timeout time.Duration
+}
+
+// NewPaymentStatusCollector exposes the counts returned by count, each scrape waits at most timeout
+func NewPaymentStatusCollector(count StatusCounter, timeout time.Duration) prometheus.Collector {
+	return &paymentStatusCollector{count: count, timeout: timeout}
+}
+
+func (c *paymentStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- paymentsByStatusDesc
+	ch <- scrapeErrorsDesc
+}
+
+func (c *paymentStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	counts, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(scrapeErrorsDesc, prometheus.GaugeValue, 1)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(scrapeErrorsDesc, prometheus.GaugeValue, 0)
+	for status, n := range counts {
+		ch <- prometheus.MustNewConstMetric(paymentsByStatusDesc, prometheus.GaugeValue, float64(n), status)
+	}
+}
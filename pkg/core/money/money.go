@@ -0,0 +1,87 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrUnknownCurrency is returned for codes that are not in the ISO-4217 table below
+	ErrUnknownCurrency = errors.New("unknown currency")
+	// ErrCurrencyMismatch is returned when combining amounts of different currencies
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// DefaultCurrency is assumed for legacy rows stored before currencies were recorded
+const DefaultCurrency = "USD"
+
+// exponents maps ISO-4217 codes to the number of minor unit digits.
+// Currencies missing here are rejected.
+var exponents = map[string]int{
+	"USD": 2, "EUR": 2, "GBP": 2, "AUD": 2, "CAD": 2, "CHF": 2, "CNY": 2,
+	"HKD": 2, "INR": 2, "MYR": 2, "NZD": 2, "PHP": 2, "SGD": 2, "THB": 2,
+	"IDR": 2, "TWD": 2,
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "ISK": 0,
+	"BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+// Money is an exact amount expressed in the minor unit of its currency, eg. cents for USD
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// New validates the currency and returns the amount in minor units
+func New(amount int64, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if _, ok := exponents[currency]; !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// Exponent returns the number of minor unit digits of an ISO-4217 currency
+func Exponent(currency string) (int, error) {
+	exp, ok := exponents[strings.ToUpper(currency)]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	return exp, nil
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount - o.Amount, Currency: m.Currency}, nil
+}
+
+// String formats the amount in major units using the currency exponent, eg. "12.34 USD"
+func (m Money) String() string {
+	exp, err := Exponent(m.Currency)
+	if err != nil || exp == 0 {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	div := int64(1)
+	for i := 0; i < exp; i++ {
+		div *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d %s", sign, amount/div, exp, amount%div, m.Currency)
+}
@@ -0,0 +1,56 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExponent(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     int
+		wantErr  error
+	}{
+		{currency: "USD", want: 2},
+		{currency: "usd", want: 2},
+		{currency: "JPY", want: 0},
+		{currency: "KWD", want: 3},
+		{currency: "XXX", wantErr: ErrUnknownCurrency},
+		{currency: "", wantErr: ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			got, err := Exponent(tt.currency)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Exponent(%q) error = %v, want %v", tt.currency, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Exponent(%q) = %d, want %d", tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		name  string
+		money Money
+		want  string
+	}{
+		{name: "two digits", money: Money{Amount: 1234, Currency: "USD"}, want: "12.34 USD"},
+		{name: "leading zero cents", money: Money{Amount: 1205, Currency: "EUR"}, want: "12.05 EUR"},
+		{name: "below one unit", money: Money{Amount: 7, Currency: "USD"}, want: "0.07 USD"},
+		{name: "negative", money: Money{Amount: -1234, Currency: "USD"}, want: "-12.34 USD"},
+		{name: "negative below one unit", money: Money{Amount: -5, Currency: "USD"}, want: "-0.05 USD"},
+		{name: "zero exponent", money: Money{Amount: 1500, Currency: "JPY"}, want: "1500 JPY"},
+		{name: "three digits", money: Money{Amount: 1005, Currency: "KWD"}, want: "1.005 KWD"},
+		{name: "unknown currency", money: Money{Amount: 42, Currency: "XXX"}, want: "42 XXX"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.money.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/propagation"
+	"net/http"
+)
+
+// KafkaCarrier reads and writes trace context in Kafka message headers
+type KafkaCarrier struct {
+	Headers *[]kafka.Header
+}
+
+func (c KafkaCarrier) Get(key string) string {
+	for _, h := range *c.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c KafkaCarrier) Set(key, value string) {
+	for i, h := range *c.Headers {
+		if h.Key == key {
+			(*c.Headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.Headers = append(*c.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c KafkaCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.Headers))
+	for _, h := range *c.Headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+// TraceParentKey is the W3C header carrying the trace context
+const TraceParentKey = "traceparent"
+
+// TraceParent returns the W3C traceparent of ctx, empty when ctx carries no span. It is
+// stored next to work that continues later, eg. outbox events.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	Inject(ctx, carrier)
+	return carrier.Get(TraceParentKey)
+}
+
+// WithTraceParent returns ctx continuing the trace of a stored traceparent
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return Extract(ctx, propagation.MapCarrier{TraceParentKey: traceParent})
+}
+
+// ExtractHTTP continues the trace of incoming requests carrying W3C headers
+func ExtractHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "93.184.216.34", want: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{ip: "127.0.0.1"},
+		{ip: "::1"},
+		{ip: "10.0.0.1"},
+		{ip: "172.16.5.4"},
+		{ip: "192.168.1.1"},
+		{ip: "169.254.169.254"},
+		{ip: "100.64.0.1"},
+		{ip: "0.0.0.0"},
+		{ip: "224.0.0.1"},
+		{ip: "fe80::1"},
+		{ip: "fd00::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := IsPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("IsPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		wantErr   bool
+		forbidden bool
+	}{
+		{name: "public ip", url: "https://93.184.216.34/hooks"},
+		{name: "not a url", url: "://nope", wantErr: true},
+		{name: "relative", url: "/hooks", wantErr: true},
+		{name: "other scheme", url: "ftp://93.184.216.34/hooks", wantErr: true},
+		{name: "loopback", url: "http://127.0.0.1:8080/hooks", wantErr: true, forbidden: true},
+		{name: "metadata service", url: "http://169.254.169.254/latest", wantErr: true, forbidden: true},
+		{name: "private ipv6", url: "http://[fd00::1]/hooks", wantErr: true, forbidden: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckURL(context.Background(), tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckURL(%q) error = %v, want error %v", tt.url, err, tt.wantErr)
+			}
+			if errors.Is(err, ErrForbiddenAddress) != tt.forbidden {
+				t.Errorf("CheckURL(%q) error = %v, want ErrForbiddenAddress %v", tt.url, err, tt.forbidden)
+			}
+		})
+	}
+}
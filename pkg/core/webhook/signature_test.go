@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	sig := Sign("whsec_test", 1700000000, []byte(`{"id":"1"}`))
+	if !strings.HasPrefix(sig, signaturePrefix) {
+		t.Fatalf("Sign() = %q, want prefix %q", sig, signaturePrefix)
+	}
+	if len(sig) != len(signaturePrefix)+64 {
+		t.Errorf("Sign() = %q, want a hex SHA-256 after the prefix", sig)
+	}
+	if again := Sign("whsec_test", 1700000000, []byte(`{"id":"1"}`)); again != sig {
+		t.Errorf("Sign() is not deterministic: %q then %q", sig, again)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	const (
+		secret    = "whsec_test"
+		timestamp = int64(1700000000)
+	)
+	body := []byte(`{"id":"1"}`)
+	sig := Sign(secret, timestamp, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{name: "valid", secret: secret, timestamp: timestamp, body: body, signature: sig, want: true},
+		{name: "other secret", secret: "whsec_other", timestamp: timestamp, body: body, signature: sig},
+		{name: "other timestamp", secret: secret, timestamp: timestamp + 1, body: body, signature: sig},
+		{name: "tampered body", secret: secret, timestamp: timestamp, body: []byte(`{"id":"2"}`), signature: sig},
+		{name: "missing prefix", secret: secret, timestamp: timestamp, body: body, signature: strings.TrimPrefix(sig, signaturePrefix)},
+		{name: "empty signature", secret: secret, timestamp: timestamp, body: body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.timestamp, tt.body, tt.signature); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,18 +4,16 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
-	"payment/pkg/core/configloader"
 	"payment/pkg/http/utils/app_errors"
 	"strings"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware only lets through admin tokens signed with accessSecret
+func AuthMiddleware(accessSecret string) gin.HandlerFunc {
+	signature := []byte(accessSecret)
 	return func(c *gin.Context) {
 
-		config := configloader.GetConfig()
-		JWTAccessSecure := config.JWTAccessSecure
 		authHeader := c.GetHeader("Authorization")
-		signature := []byte(JWTAccessSecure)
 
 		if authHeader == "" {
 			err := app_errors.AppError("fail to authenticate", app_errors.StatusValidationError)
@@ -35,6 +33,9 @@ func AuthMiddleware() gin.HandlerFunc {
 		tokenString := authHeaderParts[1]
 		claims := jwt.MapClaims{}
 		_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
 			return signature, nil
 		})
 
@@ -3,7 +3,6 @@ package app_errors
 import (
 	"errors"
 	"github.com/gin-gonic/gin"
-	"net/http"
 	"payment/pkg/http/utils"
 )
 
@@ -18,14 +17,26 @@ const (
 	StatusCreated             = "Created successfully"
 	StatusGatewayTimeout      = "Gateway time out"
 	StatusConflict            = "Your input has been conflict with another data"
+	StatusAborted             = "Your request raced with another change, retry it"
+	StatusAlreadyExists       = "Your input has been used for another request"
 	StatusTooManyRequests     = "Too many request"
 	StatusValidationError     = "Validation has been failed"
+	StatusServiceUnavailable  = "Service is temporarily unavailable"
 )
 
 // Response trả về cho APP FE khi có lỗi
 type ErrorResponse struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
+	// Reason is a stable machine readable cause, eg. IDEMPOTENCY_KEY_MISMATCH
+	Reason     string           `json:"reason,omitempty"`
+	Violations []FieldViolation `json:"violations,omitempty"`
+}
+
+// FieldViolation describes one invalid request field
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
 }
 
 type ResponseError struct {
@@ -45,6 +56,53 @@ func AppError(err string, errType string) *ResponseError {
 	}
 }
 
+// Validation returns a validation error listing every invalid field
+func Validation(violations ...FieldViolation) *ResponseError {
+	err := AppError(StatusValidationError, StatusValidationError)
+	err.ErrorResp.Violations = violations
+	return err
+}
+
+// NotFound returns a not found error with a machine readable reason
+func NotFound(reason string) *ResponseError {
+	return withReason(AppError(StatusNotFound, StatusNotFound), reason)
+}
+
+// Conflict returns a conflict error with a machine readable reason
+func Conflict(reason string, message string) *ResponseError {
+	return withReason(AppError(message, StatusConflict), reason)
+}
+
+// Aborted returns an error for a change lost to a concurrent one, the call may be retried
+func Aborted(reason string, message string) *ResponseError {
+	return withReason(AppError(message, StatusAborted), reason)
+}
+
+// AlreadyExists returns an error for a key already used by a different request
+func AlreadyExists(reason string, message string) *ResponseError {
+	return withReason(AppError(message, StatusAlreadyExists), reason)
+}
+
+// GatewayTimeout returns an error for an upstream call that did not answer in time
+func GatewayTimeout(reason string) *ResponseError {
+	return withReason(AppError(StatusGatewayTimeout, StatusGatewayTimeout), reason)
+}
+
+// Unavailable returns an error for a dependency that is temporarily down, the call may be retried
+func Unavailable(reason string) *ResponseError {
+	return withReason(AppError(StatusServiceUnavailable, StatusServiceUnavailable), reason)
+}
+
+// Internal returns an internal error that does not leak the underlying cause
+func Internal() *ResponseError {
+	return AppError(StatusInternalServerError, StatusInternalServerError)
+}
+
+func withReason(err *ResponseError, reason string) *ResponseError {
+	err.ErrorResp.Reason = reason
+	return err
+}
+
 type MetaResponse struct {
 	TraceID string `json:"traceId"`
 	Success bool   `json:"success"`
@@ -70,50 +128,11 @@ func ErrorHandler(c *gin.Context) {
 				Meta: MetaResponse{
 					TraceID: meta.TraceID,
 				},
-				Err: ErrorResponse{
-					Code:    err.ErrorResp.Code,
-					Message: err.ErrorResp.Message,
-				},
+				Err: err.ErrorResp,
 			}
 
-			switch err.ErrorResp.Code {
-
-			case StatusOK:
-				c.JSON(http.StatusOK, resp)
-				return
-			case StatusBadRequest:
-				c.JSON(http.StatusBadRequest, resp)
-				return
-			case StatusUnauthorized:
-				c.JSON(http.StatusUnauthorized, resp)
-				return
-			case StatusForbidden:
-				c.JSON(http.StatusForbidden, resp)
-				return
-			case StatusNotFound:
-				c.JSON(http.StatusNotFound, resp)
-				return
-			case StatusConflict:
-				c.JSON(http.StatusConflict, resp)
-				return
-			case StatusGatewayTimeout:
-				c.JSON(http.StatusGatewayTimeout, resp)
-				return
-			case StatusTooManyRequests:
-				c.JSON(http.StatusTooManyRequests, resp)
-				return
-			case StatusCreated:
-				c.JSON(http.StatusCreated, resp)
-				return
-			case StatusInternalServerError:
-				c.JSON(http.StatusInternalServerError, resp)
-				return
-			case StatusValidationError:
-				c.JSON(http.StatusBadRequest, resp)
-			default:
-				c.JSON(http.StatusInternalServerError, resp)
-				return
-			}
+			c.JSON(HTTPStatus(err), resp)
+			return
 		}
 		return
 	}
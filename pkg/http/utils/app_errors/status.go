@@ -0,0 +1,112 @@
+package app_errors
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"net/http"
+	"payment/pkg/http/utils"
+)
+
+type statusMapping struct {
+	http int
+	grpc codes.Code
+}
+
+// statusTable is the single translation from error codes to HTTP statuses and gRPC codes
+var statusTable = map[string]statusMapping{
+	StatusOK:                  {http.StatusOK, codes.OK},
+	StatusCreated:             {http.StatusCreated, codes.OK},
+	StatusBadRequest:          {http.StatusBadRequest, codes.InvalidArgument},
+	StatusValidationError:     {http.StatusBadRequest, codes.InvalidArgument},
+	StatusUnauthorized:        {http.StatusUnauthorized, codes.Unauthenticated},
+	StatusForbidden:           {http.StatusForbidden, codes.PermissionDenied},
+	StatusNotFound:            {http.StatusNotFound, codes.NotFound},
+	StatusConflict:            {http.StatusConflict, codes.FailedPrecondition},
+	StatusAborted:             {http.StatusConflict, codes.Aborted},
+	StatusAlreadyExists:       {http.StatusConflict, codes.AlreadyExists},
+	StatusTooManyRequests:     {http.StatusTooManyRequests, codes.ResourceExhausted},
+	StatusGatewayTimeout:      {http.StatusGatewayTimeout, codes.DeadlineExceeded},
+	StatusServiceUnavailable:  {http.StatusServiceUnavailable, codes.Unavailable},
+	StatusInternalServerError: {http.StatusInternalServerError, codes.Internal},
+}
+
+// HTTPStatus returns the HTTP status for err, unknown codes are internal errors
+func HTTPStatus(err *ResponseError) int {
+	if m, ok := statusTable[err.ErrorResp.Code]; ok {
+		return m.http
+	}
+	return http.StatusInternalServerError
+}
+
+// Retryable reports whether the same request may succeed later, eg. after a timeout or
+// while a dependency is down or a concurrent change. Validation, not found and conflict
+// errors are final.
+func (er *ResponseError) Retryable() bool {
+	switch er.ErrorResp.Code {
+	case StatusAborted, StatusGatewayTimeout, StatusServiceUnavailable, StatusTooManyRequests, StatusInternalServerError:
+		return true
+	}
+	return false
+}
+
+// GRPCStatus converts the error into a gRPC status carrying BadRequest field
+// violations and an ErrorInfo reason. It lets grpc-go translate a returned
+// *ResponseError without help from the handler.
+func (er *ResponseError) GRPCStatus() *status.Status {
+	code := codes.Internal
+	if m, ok := statusTable[er.ErrorResp.Code]; ok {
+		code = m.grpc
+	}
+	st := status.New(code, er.ErrorResp.Message)
+
+	var details []protoadapt.MessageV1
+	if len(er.ErrorResp.Violations) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, v := range er.ErrorResp.Violations {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
+		}
+		details = append(details, br)
+	}
+	if er.ErrorResp.Reason != "" {
+		details = append(details, &errdetails.ErrorInfo{
+			Reason: er.ErrorResp.Reason,
+			Domain: utils.APPNAME,
+		})
+	}
+	if len(details) == 0 {
+		return st
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		return withDetails
+	}
+	return st
+}
+
+// ToGRPC converts any error returned by a service into a gRPC status error.
+// Errors that already carry a status are kept, context errors keep their meaning
+// and everything else becomes codes.Internal without leaking the cause.
+func ToGRPC(err error) error {
+	if err == nil {
+		return nil
+	}
+	var appErr *ResponseError
+	switch {
+	case errors.As(err, &appErr):
+		return appErr.GRPCStatus().Err()
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	if st, ok := status.FromError(err); ok {
+		return st.Err()
+	}
+	return Internal().GRPCStatus().Err()
+}
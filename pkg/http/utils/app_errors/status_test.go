@@ -0,0 +1,71 @@
+package app_errors
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"testing"
+)
+
+func TestStatusMapping(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       *ResponseError
+		http      int
+		grpc      codes.Code
+		retryable bool
+	}{
+		{name: "validation", err: Validation(FieldViolation{Field: "amount", Description: "is required"}),
+			http: http.StatusBadRequest, grpc: codes.InvalidArgument},
+		{name: "not found", err: NotFound("PAYMENT_NOT_FOUND"), http: http.StatusNotFound, grpc: codes.NotFound},
+		{name: "conflict", err: Conflict("INVALID_STATUS_TRANSITION", "not capturable"),
+			http: http.StatusConflict, grpc: codes.FailedPrecondition},
+		{name: "aborted", err: Aborted("CONCURRENT_UPDATE", "modified concurrently"),
+			http: http.StatusConflict, grpc: codes.Aborted, retryable: true},
+		{name: "already exists", err: AlreadyExists("IDEMPOTENCY_KEY_MISMATCH", "key reused"),
+			http: http.StatusConflict, grpc: codes.AlreadyExists},
+		{name: "gateway timeout", err: GatewayTimeout("GATEWAY_TIMEOUT"),
+			http: http.StatusGatewayTimeout, grpc: codes.DeadlineExceeded, retryable: true},
+		{name: "unavailable", err: Unavailable("STORE_UNAVAILABLE"),
+			http: http.StatusServiceUnavailable, grpc: codes.Unavailable, retryable: true},
+		{name: "internal", err: Internal(), http: http.StatusInternalServerError, grpc: codes.Internal, retryable: true},
+		{name: "unknown code", err: AppError("boom", "no such code"), http: http.StatusInternalServerError, grpc: codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTTPStatus(tt.err); got != tt.http {
+				t.Errorf("HTTPStatus() = %d, want %d", got, tt.http)
+			}
+			if got := tt.err.GRPCStatus().Code(); got != tt.grpc {
+				t.Errorf("GRPCStatus().Code() = %s, want %s", got, tt.grpc)
+			}
+			if got := tt.err.Retryable(); got != tt.retryable {
+				t.Errorf("Retryable() = %v, want %v", got, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestToGRPC(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{name: "nil", err: nil, want: codes.OK},
+		{name: "wrapped app error", err: errors.Join(errors.New("context"), Aborted("CONCURRENT_UPDATE", "raced")), want: codes.Aborted},
+		{name: "cancelled", err: context.Canceled, want: codes.Canceled},
+		{name: "deadline", err: context.DeadlineExceeded, want: codes.DeadlineExceeded},
+		{name: "status kept", err: status.Error(codes.PermissionDenied, "no"), want: codes.PermissionDenied},
+		{name: "anything else", err: errors.New("db password is hunter2"), want: codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(ToGRPC(tt.err)); got != tt.want {
+				t.Errorf("ToGRPC() code = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
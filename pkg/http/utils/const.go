@@ -5,5 +5,7 @@ import "time"
 const (
 	APPNAME             = "payment_service"
 	HeaderXRequestID    = "x-request-id"
+	ContextKeyActor     = "actor"
+	DefaultActor        = "system"
 	GeneralQueryTimeout = 60 * time.Second
 )
@@ -18,3 +18,22 @@ func NewMetaData(ctx context.Context) *MetaData {
 		Success: true,
 	}
 }
+
+// WithActor records who is acting on behalf of the request, eg. a user role or a consumer name
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, ContextKeyActor, actor)
+}
+
+// ActorFromContext returns the actor set with WithActor or DefaultActor
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(ContextKeyActor).(string); ok && actor != "" {
+		return actor
+	}
+	return DefaultActor
+}
+
+// RequestIDFromContext returns the x-request-id stored by the request id middleware
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(HeaderXRequestID).(string)
+	return requestID
+}